@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/michaelcourcy/audit-tool/pkg/action"
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/dynamic"
+)
+
+// namespaceActions are the actions of the application of one namespace.
+type namespaceActions struct {
+	backups  []action.BackupAction
+	exports  []action.ExportAction
+	restores []action.RestoreAction
+}
+
+// actionIndex groups the actions of the whole cluster by the namespace of
+// their subject, the namespace they live in can differ when a policy runs
+// them from the kasten namespace.
+type actionIndex struct {
+	namespaces map[string]*namespaceActions
+	// scanned is the number of actions read
+	scanned int
+	// exports and restores tell if the exportactions and restoreactions
+	// could be listed
+	exports  bool
+	restores bool
+}
+
+var actionsPermissions = []permission.Permission{
+	{Group: action.GroupName, Resource: action.BackupActionResource.Resource, Verb: "list"},
+	{Group: action.GroupName, Resource: action.ExportActionResource.Resource, Verb: "list", Optional: true},
+	{Group: action.GroupName, Resource: action.RestoreActionResource.Resource, Verb: "list", Optional: true},
+}
+
+// indexActions lists the backupactions, exportactions and restoreactions of
+// all namespaces at once instead of one request per namespace. The
+// exportactions and restoreactions are left out when they are forbidden.
+func indexActions(ctx context.Context, dynamicClient dynamic.Interface) (*actionIndex, error) {
+	index := &actionIndex{namespaces: map[string]*namespaceActions{}}
+
+	backups := action.BackupActionList{}
+	err := client.List(ctx, dynamicClient, action.BackupActionResource, "", &backups)
+	if err != nil {
+		return nil, err
+	}
+	for _, backupAction := range backups.Items {
+		actions := index.namespace(subjectNamespace(backupAction.Spec.Subject.Namespace, backupAction.Namespace))
+		actions.backups = append(actions.backups, backupAction)
+	}
+	index.scanned += len(backups.Items)
+
+	exports := action.ExportActionList{}
+	err = client.List(ctx, dynamicClient, action.ExportActionResource, "", &exports)
+	if err != nil && !errors.IsForbidden(err) {
+		return nil, err
+	}
+	index.exports = err == nil
+	for _, exportAction := range exports.Items {
+		actions := index.namespace(subjectNamespace(exportAction.Spec.Subject.Namespace, exportAction.Namespace))
+		actions.exports = append(actions.exports, exportAction)
+	}
+	index.scanned += len(exports.Items)
+
+	restores := action.RestoreActionList{}
+	err = client.List(ctx, dynamicClient, action.RestoreActionResource, "", &restores)
+	if err != nil && !errors.IsForbidden(err) {
+		return nil, err
+	}
+	index.restores = err == nil
+	for _, restoreAction := range restores.Items {
+		namespace := restoreAction.Spec.TargetNamespace
+		if namespace == "" {
+			namespace = subjectNamespace(restoreAction.Spec.Subject.Namespace, restoreAction.Namespace)
+		}
+		actions := index.namespace(namespace)
+		actions.restores = append(actions.restores, restoreAction)
+	}
+	index.scanned += len(restores.Items)
+	return index, nil
+}
+
+// actionIndex lists the actions of the cluster the first time a check needs
+// them.
+func (a *auditor) actionIndex(ctx context.Context) (*actionIndex, error) {
+	if a.actions == nil {
+		index, err := indexActions(ctx, a.dynamicClient)
+		if err != nil {
+			return nil, err
+		}
+		a.actions = index
+	}
+	return a.actions, nil
+}
+
+// namespace returns the actions of the namespace, created when it has none.
+func (index *actionIndex) namespace(namespace string) *namespaceActions {
+	actions, ok := index.namespaces[namespace]
+	if !ok {
+		actions = &namespaceActions{}
+		index.namespaces[namespace] = actions
+	}
+	return actions
+}
+
+// get returns the actions of the namespace, safe to call from the workers
+// once the index is built.
+func (index *actionIndex) get(namespace string) namespaceActions {
+	if actions, ok := index.namespaces[namespace]; ok {
+		return *actions
+	}
+	return namespaceActions{}
+}
+
+// subjectNamespace is the namespace of the subject, or the namespace of the
+// action when the subject has none.
+func subjectNamespace(subject string, namespace string) string {
+	if subject != "" {
+		return subject
+	}
+	return namespace
+}
+
+// backupStats summarizes the finished backupactions of a namespace.
+type backupStats struct {
+	complete int
+	failed   int
+	// meanDuration is the mean duration of the complete actions
+	meanDuration time.Duration
+	// failureStreak is the number of actions which failed since the last
+	// complete one
+	failureStreak int
+	// last is the complete action which ended last, nil when there is none
+	last *action.BackupAction
+}
+
+// sortBackupActions sorts the actions newest first by their scheduled time,
+// the API does not return them in any particular order.
+func sortBackupActions(backupActions []action.BackupAction) {
+	sort.SliceStable(backupActions, func(i, j int) bool {
+		if !backupActions[i].Start().Equal(backupActions[j].Start()) {
+			return backupActions[i].Start().After(backupActions[j].Start())
+		}
+		return backupActions[i].Name > backupActions[j].Name
+	})
+}
+
+// computeBackupStats expects the actions sorted newest first, the actions
+// still running are left out.
+func computeBackupStats(backupActions []action.BackupAction) backupStats {
+	stats := backupStats{}
+	var total time.Duration
+	streak := true
+	for i := range backupActions {
+		backupAction := &backupActions[i]
+		switch backupAction.Status.State {
+		case "Complete":
+			stats.complete++
+			streak = false
+			if duration, ok := backupAction.Duration(); ok {
+				total += duration
+			}
+			if stats.last == nil || backupAction.Status.EndTime.After(stats.last.Status.EndTime) {
+				stats.last = backupAction
+			}
+		case "Failed":
+			stats.failed++
+			if streak {
+				stats.failureStreak++
+			}
+		}
+	}
+	if stats.complete > 0 {
+		stats.meanDuration = total / time.Duration(stats.complete)
+	}
+	return stats
+}
+
+// successRate is the percentage of the finished actions which succeeded.
+func (stats backupStats) successRate() int {
+	if stats.complete+stats.failed == 0 {
+		return 0
+	}
+	return 100 * stats.complete / (stats.complete + stats.failed)
+}
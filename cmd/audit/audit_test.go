@@ -0,0 +1,2649 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelcourcy/audit-tool/pkg/action"
+	"github.com/michaelcourcy/audit-tool/pkg/blueprint"
+	"github.com/michaelcourcy/audit-tool/pkg/blueprintbinding"
+	"github.com/michaelcourcy/audit-tool/pkg/history"
+	"github.com/michaelcourcy/audit-tool/pkg/license"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/policy"
+	"github.com/michaelcourcy/audit-tool/pkg/profile"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"github.com/michaelcourcy/audit-tool/pkg/restorepoint"
+	"github.com/michaelcourcy/audit-tool/pkg/snapshot"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespace = "kasten-io"
+
+// testNow is the clock of the tests, the fixtures are dated from it.
+var testNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// defaultKubeletStats and defaultContextAuditor reach the cluster, the
+// tests replace them.
+var (
+	defaultKubeletStats   = kubeletStats
+	defaultContextAuditor = contextAuditor
+)
+
+func init() {
+	now = func() time.Time { return testNow }
+}
+
+// newTestReport returns a report writing in the returned buffer.
+func newTestReport() (*report.Report, *bytes.Buffer) {
+	var out bytes.Buffer
+	return report.New(&out), &out
+}
+
+// fakeDynamic returns a dynamic client serving the Kasten objects.
+func fakeDynamic(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	listKinds := map[schema.GroupVersionResource]string{
+		action.BackupActionResource:               "BackupActionList",
+		action.ExportActionResource:               "ExportActionList",
+		action.RestoreActionResource:              "RestoreActionList",
+		restorepoint.RestorePointResource:         "RestorePointList",
+		profile.ProfileResource:                   "ProfileList",
+		policy.PolicyResource:                     "PolicyList",
+		blueprint.BlueprintResource:               "BlueprintList",
+		blueprintbinding.BlueprintBindingResource: "BlueprintBindingList",
+		clusterVersionResource:                    "ClusterVersionList",
+		clusterResource:                           "ClusterList",
+		distributionResource:                      "DistributionList",
+		routeResource:                             "RouteList",
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+}
+
+// kastenObject builds a Kasten resource of the fake dynamic client.
+func kastenObject(gvr schema.GroupVersionResource, kind string, namespace string, name string, content map[string]interface{}) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{Object: content}
+	object.SetAPIVersion(gvr.GroupVersion().String())
+	object.SetKind(kind)
+	object.SetNamespace(namespace)
+	object.SetName(name)
+	return object
+}
+
+func profileFixture(name string, validation string, protectionPeriod string) *unstructured.Unstructured {
+	return kastenObject(profile.ProfileResource, "Profile", testNamespace, name, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"type": "Location",
+			"locationSpec": map[string]interface{}{
+				"location": map[string]interface{}{
+					"locationType": "ObjectStore",
+					"objectStore": map[string]interface{}{
+						"protectionPeriod": protectionPeriod,
+					},
+				},
+			},
+		},
+		"status": map[string]interface{}{"validation": validation},
+	})
+}
+
+func backupActionFixture(namespace string, name string, state string, age time.Duration) *unstructured.Unstructured {
+	object := kastenObject(action.BackupActionResource, "BackupAction", namespace, name, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"subject":       map[string]interface{}{"namespace": namespace},
+			"scheduledTime": testNow.Add(-age - 6*time.Minute).Format(time.RFC3339),
+		},
+		"status": map[string]interface{}{
+			"state":     state,
+			"startTime": testNow.Add(-age - 5*time.Minute).Format(time.RFC3339),
+			"endTime":   testNow.Add(-age).Format(time.RFC3339),
+		},
+	})
+	object.SetCreationTimestamp(metav1.NewTime(testNow.Add(-age - time.Minute)))
+	return object
+}
+
+// recreated gives the action a recent creation timestamp, like an action
+// restored with the catalog, while it was scheduled long ago.
+func recreated(object *unstructured.Unstructured) *unstructured.Unstructured {
+	object.SetCreationTimestamp(metav1.NewTime(testNow))
+	return object
+}
+
+// messages returns the messages of the findings with this severity.
+func messages(r *report.Report, severity report.Severity) []string {
+	var result []string
+	for _, finding := range r.Findings {
+		if finding.Severity == severity {
+			result = append(result, finding.Message)
+		}
+	}
+	return result
+}
+
+// assertFindings checks that every expected message is a part of one of the
+// findings and that there are no more findings than expected.
+func assertFindings(t *testing.T, r *report.Report, severity report.Severity, expected []string) {
+	t.Helper()
+	found := messages(r, severity)
+	if len(found) != len(expected) {
+		t.Errorf("expected %d %s findings, got %d: %q", len(expected), severity, len(found), found)
+		return
+	}
+	for i, message := range expected {
+		if !strings.Contains(found[i], message) {
+			t.Errorf("expected %s finding %q to contain %q", severity, found[i], message)
+		}
+	}
+}
+
+func TestProfilesAudit(t *testing.T) {
+	tests := []struct {
+		name      string
+		profiles  []runtime.Object
+		warnings  []string
+		immutable bool
+	}{
+		{
+			name:     "no profiles",
+			warnings: []string{"there is no profile at all"},
+		},
+		{
+			name:     "invalid profile",
+			profiles: []runtime.Object{profileFixture("s3", "Failed", "")},
+			warnings: []string{"found profile s3 which is not valid", "there is no immutable profile"},
+		},
+		{
+			name:     "mutable profile",
+			profiles: []runtime.Object{profileFixture("s3", "Success", "")},
+			warnings: []string{"there is no immutable profile"},
+		},
+		{
+			name:      "immutable profile",
+			profiles:  []runtime.Object{profileFixture("s3", "Success", "240h")},
+			immutable: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestReport()
+			err := profilesAudit(context.TODO(), r, fakeDynamic(tt.profiles...), testNamespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			if r.Summary.Immutable != tt.immutable {
+				t.Errorf("expected immutable %v, got %v", tt.immutable, r.Summary.Immutable)
+			}
+		})
+	}
+}
+
+func TestRPO(t *testing.T) {
+	tests := []struct {
+		name        string
+		actions     []runtime.Object
+		warnings    []string
+		output      string
+		unprotected int
+		worstRPO    time.Duration
+	}{
+		{
+			name:        "no backup",
+			output:      "No backupactions in namespace app",
+			unprotected: 1,
+		},
+		{
+			name:        "failed backups",
+			actions:     []runtime.Object{backupActionFixture("app", "backup-1", "Failed", time.Hour)},
+			warnings:    []string{"no backupaction were successful in namespace app"},
+			unprotected: 1,
+		},
+		{
+			name: "most recent actions",
+			actions: []runtime.Object{
+				backupActionFixture("app", "backup-a", "Complete", 50*time.Hour),
+				backupActionFixture("app", "backup-b", "Failed", 2*time.Hour),
+				backupActionFixture("app", "backup-c", "Complete", 26*time.Hour),
+			},
+			output:   "1 older backupactions not shown",
+			worstRPO: 26 * time.Hour,
+		},
+		{
+			name: "failure streak",
+			actions: []runtime.Object{
+				backupActionFixture("app", "backup-1", "Complete", 30*time.Hour),
+				backupActionFixture("app", "backup-2", "Failed", 20*time.Hour),
+				backupActionFixture("app", "backup-3", "Failed", 10*time.Hour),
+				backupActionFixture("app", "backup-4", "Failed", 2*time.Hour),
+			},
+			warnings: []string{"the last 3 backupactions of namespace app failed"},
+			output:   "25% of the finished backupactions succeeded (1/4), mean duration 5m0s",
+			worstRPO: 30 * time.Hour,
+		},
+		{
+			name: "recreated action",
+			actions: []runtime.Object{
+				recreated(backupActionFixture("app", "backup-old", "Complete", 40*time.Hour)),
+				backupActionFixture("app", "backup-new", "Complete", 5*time.Hour),
+			},
+			output:   "The last RPO is 0 days and 5 hours",
+			worstRPO: 5 * time.Hour,
+		},
+		{
+			name:     "stale backup",
+			actions:  []runtime.Object{backupActionFixture("app", "backup-1", "Complete", 74*time.Hour)},
+			output:   "The last RPO is 3 days and 2 hours",
+			worstRPO: 74 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KASTEN_MAX_ACTIONS", "2")
+			index, err := indexActions(context.TODO(), fakeDynamic(tt.actions...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, out := newTestReport()
+			rpo(r, "app", index.get("app"))
+			assertFindings(t, r, report.Warning, tt.warnings)
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("expected the output to contain %q, got %s", tt.output, out.String())
+			}
+			if r.Summary.UnprotectedNamespaces != tt.unprotected {
+				t.Errorf("expected %d unprotected namespaces, got %d", tt.unprotected, r.Summary.UnprotectedNamespaces)
+			}
+			if r.Summary.WorstRPO != tt.worstRPO {
+				t.Errorf("expected a worst RPO of %s, got %s", tt.worstRPO, r.Summary.WorstRPO)
+			}
+		})
+	}
+}
+
+func TestAuditNamespaces(t *testing.T) {
+	t.Setenv("KASTEN_CONCURRENCY", "3")
+	dynamicClient := fakeDynamic(
+		backupActionFixture("app-b", "backup-1", "Complete", 30*time.Hour),
+		backupActionFixture("app-c", "backup-1", "Failed", time.Hour),
+		backupActionFixture("app-d", "backup-1", "Complete", 2*time.Hour),
+	)
+	namespaces := []string{"app-d", "app-c", "app-a", "app-b"}
+	index, err := indexActions(context.TODO(), dynamicClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, out := newTestReport()
+	scanned := auditNamespaces(r, namespaces, func(namespace string) string {
+		return "namespace " + namespace
+	}, index)
+	if scanned != 3 {
+		t.Errorf("expected 3 backupactions scanned, got %d", scanned)
+	}
+	last := -1
+	for _, namespace := range []string{"app-a", "app-b", "app-c", "app-d"} {
+		i := strings.Index(out.String(), "namespace "+namespace)
+		if i < last {
+			t.Errorf("expected namespace %s to be printed in order, got %s", namespace, out.String())
+		}
+		last = i
+	}
+	assertFindings(t, r, report.Warning, []string{"no backupaction were successful in namespace app-c"})
+	if r.Summary.UnprotectedNamespaces != 2 || r.Summary.WorstRPO != 30*time.Hour {
+		t.Errorf("unexpected summary %+v", r.Summary)
+	}
+}
+
+func TestIndexActions(t *testing.T) {
+	// a backup run from the kasten namespace for the application app
+	policyBackup := backupActionFixture(testNamespace, "policy-backup", "Complete", time.Hour)
+	unstructured.SetNestedField(policyBackup.Object, "app", "spec", "subject", "namespace")
+	export := kastenObject(action.ExportActionResource, "ExportAction", testNamespace, "export-1", map[string]interface{}{
+		"spec":   map[string]interface{}{"subject": map[string]interface{}{"kind": "RestorePoint", "namespace": "app"}},
+		"status": map[string]interface{}{"state": "Complete"},
+	})
+	restore := kastenObject(action.RestoreActionResource, "RestoreAction", "app", "restore-1", map[string]interface{}{
+		"spec":   map[string]interface{}{"subject": map[string]interface{}{"namespace": "app"}, "targetNamespace": "app-copy"},
+		"status": map[string]interface{}{"state": "Failed"},
+	})
+
+	index, err := indexActions(context.TODO(), fakeDynamic(policyBackup, backupActionFixture("app", "backup-1", "Failed", 2*time.Hour), export, restore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := index.get("app")
+	if len(app.backups) != 2 || len(app.exports) != 1 || len(app.restores) != 0 {
+		t.Errorf("expected 2 backups and 1 export in app, got %+v", app)
+	}
+	if len(index.get(testNamespace).backups) != 0 {
+		t.Errorf("expected no action attributed to %s", testNamespace)
+	}
+	if len(index.get("app-copy").restores) != 1 {
+		t.Errorf("expected the restore attributed to its target namespace app-copy")
+	}
+	if index.scanned != 4 || !index.exports || !index.restores {
+		t.Errorf("unexpected index %+v", index)
+	}
+}
+
+// failedBackupFixture is a failed backupaction whose error is caused by
+// cause, nested as JSON like Kasten records it.
+func failedBackupFixture(namespace string, name string, age time.Duration, cause map[string]interface{}) *unstructured.Unstructured {
+	object := backupActionFixture(namespace, name, "Failed", age)
+	encoded, _ := json.Marshal(cause)
+	unstructured.SetNestedField(object.Object, map[string]interface{}{
+		"message": "Job failed to be executed",
+		"cause":   string(encoded),
+	}, "status", "error")
+	return object
+}
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         action.Error
+		description string
+	}{
+		{
+			name:        "profile field",
+			err:         action.Error{Message: "Failed to export", Fields: []action.Field{{Name: "profile", Value: "s3-prod"}}, Cause: `{"message":"dial tcp: connection refused"}`},
+			description: "profile s3-prod cannot be reached",
+		},
+		{
+			name:        "profile in the message",
+			err:         action.Error{Message: "Failed to export", Cause: `{"message":"Failed to connect to profile s3-dr: no such host"}`},
+			description: "profile s3-dr cannot be reached",
+		},
+		{
+			name:        "snapshot timeout",
+			err:         action.Error{Message: "Failed to snapshot volumes", Cause: `{"message":"context deadline exceeded"}`},
+			description: "the volume snapshots time out",
+		},
+		{
+			name:        "kanister",
+			err:         action.Error{Message: "Kanister action failed", Fields: []action.Field{{Name: "blueprint", Value: "mysql-bp"}}},
+			description: "the Kanister action of blueprint mysql-bp failed",
+		},
+		{
+			name:        "quota",
+			err:         action.Error{Message: "pods \"copy-vol\" is forbidden: exceeded quota: compute"},
+			description: "a resource quota is exceeded",
+		},
+		{
+			name:        "snapshot timeout naming the profile",
+			err:         action.Error{Message: "Failed to snapshot volumes for profile s3-prod", Cause: `{"message":"context deadline exceeded"}`},
+			description: "the volume snapshots time out",
+		},
+		{
+			name:        "kanister naming the profile",
+			err:         action.Error{Message: "Kanister action failed", Fields: []action.Field{{Name: "blueprint", Value: "mysql-bp"}, {Name: "profile", Value: "s3-prod"}}},
+			description: "the Kanister action of blueprint mysql-bp failed",
+		},
+		{
+			name:        "profile only named",
+			err:         action.Error{Message: "Failed to export with profile s3-prod", Cause: "disk on fire"},
+			description: "disk on fire",
+		},
+		{
+			name:        "unknown cause",
+			err:         action.Error{Message: "Job failed", Cause: "disk on fire"},
+			description: "disk on fire",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, description := classifyFailure(tt.err)
+			if description != tt.description {
+				t.Errorf("expected %q, got %q", tt.description, description)
+			}
+		})
+	}
+}
+
+func TestFailuresAudit(t *testing.T) {
+	unreachable := map[string]interface{}{"message": "Failed to reach profile s3-prod: connection refused"}
+	dynamicClient := fakeDynamic(
+		failedBackupFixture("app-a", "backup-1", time.Hour, unreachable),
+		failedBackupFixture("app-b", "backup-1", time.Hour, unreachable),
+		failedBackupFixture("app-c", "backup-1", time.Hour, map[string]interface{}{"message": "snapshot creation timed out"}),
+		// recovered since its failure
+		failedBackupFixture("app-d", "backup-1", 2*time.Hour, unreachable),
+		backupActionFixture("app-d", "backup-2", "Complete", time.Hour),
+	)
+	index, err := indexActions(context.TODO(), dynamicClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := newTestReport()
+	failuresAudit(r, index)
+	assertFindings(t, r, report.Warning, []string{
+		"2 namespaces failing because profile s3-prod cannot be reached",
+		"1 namespaces failing because the volume snapshots time out",
+	})
+}
+
+// timedBackupFixture is a complete backupaction of the policy which ended
+// age ago after running for duration.
+func timedBackupFixture(namespace string, name string, policyName string, age time.Duration, duration time.Duration) *unstructured.Unstructured {
+	object := backupActionFixture(namespace, name, "Complete", age)
+	unstructured.SetNestedField(object.Object, testNow.Add(-age-duration).Format(time.RFC3339), "status", "startTime")
+	object.SetLabels(map[string]string{policyNameLabel: policyName})
+	return object
+}
+
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for p, expected := range map[float64]time.Duration{50: 5, 90: 9, 99: 10, 0: 1} {
+		if got := percentile(durations, p); got != expected {
+			t.Errorf("expected p%v to be %d, got %d", p, expected, got)
+		}
+	}
+}
+
+func TestDurationsAudit(t *testing.T) {
+	day := 24 * time.Hour
+	daily := kastenObject(policy.PolicyResource, "Policy", testNamespace, "daily", map[string]interface{}{
+		"spec": map[string]interface{}{"frequency": "@daily"},
+	})
+	dynamicClient := fakeDynamic(
+		daily,
+		timedBackupFixture("app", "backup-1", "daily", 1*day, 5*time.Minute),
+		timedBackupFixture("app", "backup-2", "daily", 8*day, 5*time.Minute),
+		timedBackupFixture("slow", "backup-1", "hourly", 8*day, 10*time.Minute),
+		timedBackupFixture("slow", "backup-2", "hourly", 9*day, 10*time.Minute),
+		timedBackupFixture("slow", "backup-3", "hourly", 1*day, 30*time.Minute),
+		timedBackupFixture("slow", "backup-4", "hourly", 2*day, 30*time.Minute),
+		timedBackupFixture("db", "backup-1", "daily", 2*day, 20*time.Hour),
+	)
+	index, err := indexActions(context.TODO(), dynamicClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, out := newTestReport()
+	err = durationsAudit(context.TODO(), r, dynamicClient, testNamespace, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFindings(t, r, report.Warning, []string{
+		"the backupactions of namespace slow took 30m0s on average this week against 10m0s the week before",
+		"backupaction backup-1 of policy daily took 20h0m0s of its 24h0m0s interval",
+	})
+	if !strings.Contains(out.String(), "hourly") {
+		t.Errorf("expected the policy without interval to be listed, got %s", out.String())
+	}
+}
+
+func TestDiffRuns(t *testing.T) {
+	store := history.DirStore{Path: t.TempDir()}
+	runs := []history.Run{
+		{
+			Time:     testNow.Add(-24 * time.Hour),
+			Findings: []report.Finding{{ID: "Auditing profiles|there is no immutable profile", Section: "Auditing profiles", Severity: report.Warning, Message: "there is no immutable profile"}},
+			Summary:  report.Summary{RPO: map[string]time.Duration{"app": 2 * time.Hour}},
+		},
+		{
+			Time:     testNow,
+			Findings: []report.Finding{{ID: "Licensing|the license expired", Section: "Licensing", Severity: report.Critical, Message: "the license expired"}},
+			Summary:  report.Summary{Unprotected: map[string]bool{"app": true}},
+		},
+	}
+	for _, run := range runs {
+		if _, err := store.Save(context.TODO(), run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, out := newTestReport()
+	err := diffRuns(context.TODO(), r, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"1 new, 1 resolved and 0 persisting findings", "the license expired", "there is no immutable profile", "0d2h"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected the diff to contain %q, got %s", expected, out.String())
+		}
+	}
+}
+
+// fakeRelease is a releaseGetter serving one release.
+type fakeRelease struct {
+	release *release.Release
+}
+
+func (f fakeRelease) GetRelease(name string) (*release.Release, error) {
+	return f.release, nil
+}
+
+func kastenPod(name string, status v1.PodStatus) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name}, Status: status}
+}
+
+func TestCheckKastenInstall(t *testing.T) {
+	running := v1.PodStatus{
+		Phase:             v1.PodRunning,
+		ContainerStatuses: []v1.ContainerStatus{{Name: "catalog-svc", Ready: true}},
+	}
+	crashing := v1.PodStatus{
+		Phase: v1.PodRunning,
+		ContainerStatuses: []v1.ContainerStatus{{
+			Name:         "catalog-svc",
+			RestartCount: 12,
+			State:        v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		}},
+	}
+	tests := []struct {
+		name     string
+		pod      *v1.Pod
+		output   string
+		warnings []string
+	}{
+		{
+			name:   "healthy pod",
+			pod:    kastenPod("catalog-svc-0", running),
+			output: "No pods in the kasten namespace kasten-io are on error",
+		},
+		{
+			name:     "CrashLoopBackOff pod",
+			pod:      kastenPod("catalog-svc-0", crashing),
+			output:   "pod catalog-svc-0 container catalog-svc is in CrashLoopBackOff",
+			warnings: []string{"some pods in the kasten namespace kasten-io are on error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replicas := int32(1)
+			var objects []runtime.Object
+			objects = append(objects, &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNamespace}}, tt.pod)
+			for _, deployment := range kastenDeployments {
+				objects = append(objects, &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: deployment.name},
+					Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+					Status:     appsv1.DeploymentStatus{AvailableReplicas: 1},
+				})
+			}
+			helmRelease := &release.Release{Chart: &chart.Chart{Metadata: &chart.Metadata{AppVersion: "8.5.0"}}}
+
+			r, out := newTestReport()
+			_, err := checkKastenInstall(context.TODO(), r, fake.NewSimpleClientset(objects...), testNamespace, "k10", fakeRelease{helmRelease}, clusterFacts{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			assertFindings(t, r, report.Critical, nil)
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("expected the output to contain %q, got %s", tt.output, out.String())
+			}
+		})
+	}
+}
+
+func TestClusterInfo(t *testing.T) {
+	node := func(name string, ready v1.ConditionStatus) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: ready}}},
+		}
+	}
+	tests := []struct {
+		name   string
+		nodes  []runtime.Object
+		output string
+	}{
+		{
+			name:   "ready nodes",
+			nodes:  []runtime.Object{node("node-1", v1.ConditionTrue), node("node-2", v1.ConditionTrue)},
+			output: "No nodes are in error",
+		},
+		{
+			name:   "NotReady node",
+			nodes:  []runtime.Object{node("node-1", v1.ConditionTrue), node("node-2", v1.ConditionFalse)},
+			output: "Some nodes are in error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corev1Client := fake.NewSimpleClientset(tt.nodes...)
+			discoveryClient := corev1Client.Discovery().(*fakediscovery.FakeDiscovery)
+			discoveryClient.FakedServerVersion = &version.Info{Major: "1", Minor: "29", GitVersion: "v1.29.3"}
+
+			r, out := newTestReport()
+			facts, err := clusterInfo(context.TODO(), r, corev1Client, discoveryClient, fakeDynamic())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if facts.nodes != len(tt.nodes) || facts.kubernetesVersion != "v1.29.3" || facts.openshiftVersion != "" {
+				t.Errorf("unexpected facts %+v", facts)
+			}
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("expected the output to contain %q, got %s", tt.output, out.String())
+			}
+		})
+	}
+}
+
+func disasterRecoveryPolicyFixture() *unstructured.Unstructured {
+	return kastenObject(policy.PolicyResource, "Policy", testNamespace, policy.DisasterRecoveryPolicyName, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"frequency": "@daily",
+			"actions": []interface{}{
+				map[string]interface{}{
+					"action":           "backup",
+					"backupParameters": map[string]interface{}{"profile": map[string]interface{}{"name": "s3"}},
+				},
+			},
+			"kdrSnapshotConfiguration": map[string]interface{}{"exportCatalogSnapshot": true},
+		},
+		"status": map[string]interface{}{"validation": "Success"},
+	})
+}
+
+// policyAction labels the action with the policy which ran it.
+func policyAction(object *unstructured.Unstructured, policyName string) *unstructured.Unstructured {
+	object.SetLabels(map[string]string{policyNameLabel: policyName})
+	return object
+}
+
+func TestDisasterRecoveryAudit(t *testing.T) {
+	tests := []struct {
+		name      string
+		objects   []runtime.Object
+		criticals []string
+	}{
+		{
+			name:      "no disaster recovery policy",
+			objects:   []runtime.Object{profileFixture("s3", "Success", "240h")},
+			criticals: []string{"disaster recovery is not enabled"},
+		},
+		{
+			name: "recent backup",
+			objects: []runtime.Object{
+				disasterRecoveryPolicyFixture(),
+				profileFixture("s3", "Success", "240h"),
+				policyAction(backupActionFixture(testNamespace, "dr-1", "Complete", 2*time.Hour), policy.DisasterRecoveryPolicyName),
+			},
+		},
+		{
+			name: "stale backup",
+			objects: []runtime.Object{
+				disasterRecoveryPolicyFixture(),
+				profileFixture("s3", "Success", "240h"),
+				policyAction(backupActionFixture(testNamespace, "dr-1", "Complete", 72*time.Hour), policy.DisasterRecoveryPolicyName),
+			},
+			criticals: []string{"the last successful disaster recovery backup is 3 days and 0 hours old"},
+		},
+		{
+			name: "recent backup failed",
+			objects: []runtime.Object{
+				disasterRecoveryPolicyFixture(),
+				profileFixture("s3", "Success", "240h"),
+				policyAction(backupActionFixture(testNamespace, "dr-1", "Failed", 2*time.Hour), policy.DisasterRecoveryPolicyName),
+			},
+			criticals: []string{"no disaster recovery backup was successful"},
+		},
+		{
+			name: "recent backup of another policy",
+			objects: []runtime.Object{
+				disasterRecoveryPolicyFixture(),
+				profileFixture("s3", "Success", "240h"),
+				backupActionFixture(testNamespace, "manual-1", "Complete", 2*time.Hour),
+				policyAction(backupActionFixture(testNamespace, "daily-1", "Complete", 2*time.Hour), "daily"),
+			},
+			criticals: []string{"no disaster recovery backup was successful"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: disasterRecoverySecret}}
+
+			r, _ := newTestReport()
+			err := disasterRecoveryAudit(context.TODO(), r, fake.NewSimpleClientset(secret), fakeDynamic(tt.objects...), testNamespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Critical, tt.criticals)
+			assertFindings(t, r, report.Warning, nil)
+		})
+	}
+}
+
+func TestDetectDatabase(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		image    string
+		engine   string
+		operator string
+	}{
+		{name: "postgresql image", image: "docker.io/bitnami/postgresql:15.4.0@sha256:0123", engine: "PostgreSQL"},
+		{name: "percona mongodb image", image: "percona/percona-server-mongodb:6.0", engine: "MongoDB"},
+		{name: "operator label", labels: map[string]string{"cnpg.io/cluster": "db"}, image: "ghcr.io/cloudnative-pg/postgresql:16", engine: "PostgreSQL", operator: "CloudNativePG"},
+		{name: "managed-by label of another operator", labels: map[string]string{"app.kubernetes.io/managed-by": "Helm"}, image: "nginx:1.25"},
+		{name: "exporter image", image: "prom/mysqld-exporter:v0.15.0"},
+		{name: "app label", labels: map[string]string{"app": "Redis"}, image: "registry.local/cache:7", engine: "Redis"},
+		{name: "not a database", image: "nginx:1.25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "main", Image: tt.image}}}}
+			resource := blueprintbinding.Resource{Group: "apps", Resource: "statefulsets", Namespace: "app", Name: "db", Labels: tt.labels}
+			database, ok := detectDatabase(resource, "StatefulSet", template)
+			if ok != (tt.engine != "") {
+				t.Fatalf("expected detected %v, got %v", tt.engine != "", ok)
+			}
+			if database.Engine != tt.engine || database.Operator != tt.operator {
+				t.Errorf("expected %q %q, got %q %q", tt.engine, tt.operator, database.Engine, database.Operator)
+			}
+		})
+	}
+}
+
+func TestImageName(t *testing.T) {
+	tests := map[string]string{
+		"postgres":                             "postgres",
+		"mongo:7":                              "mongo",
+		"registry.local:5000/team/MySQL:8.0":   "mysql",
+		"docker.io/bitnami/redis@sha256:0123":  "redis",
+		"quay.io/org/redis:7.2@sha256:0123abc": "redis",
+	}
+	for image, expected := range tests {
+		if name := imageName(image); name != expected {
+			t.Errorf("expected the name of %s to be %s, got %s", image, expected, name)
+		}
+	}
+}
+
+func TestNodeBlockers(t *testing.T) {
+	enough := v1.ResourceList{v1.ResourceCPU: resource.MustParse("2"), v1.ResourceMemory: resource.MustParse("4Gi")}
+	ready := []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+	tests := []struct {
+		name     string
+		node     v1.Node
+		free     v1.ResourceList
+		expected []string
+	}{
+		{
+			name: "schedulable node",
+			node: v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{v1.LabelArchStable: "amd64", v1.LabelOSStable: "linux"}},
+				Status:     v1.NodeStatus{Conditions: ready},
+			},
+			free: enough,
+		},
+		{
+			name: "cordoned and not ready",
+			node: v1.Node{
+				Spec:   v1.NodeSpec{Unschedulable: true},
+				Status: v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionUnknown}}},
+			},
+			free:     enough,
+			expected: []string{"cordoned", "not ready"},
+		},
+		{
+			name: "tainted",
+			node: v1.Node{
+				Spec: v1.NodeSpec{Taints: []v1.Taint{
+					{Key: "node-role.kubernetes.io/control-plane", Effect: v1.TaintEffectNoSchedule},
+					{Key: "spot", Effect: v1.TaintEffectPreferNoSchedule},
+				}},
+				Status: v1.NodeStatus{Conditions: ready},
+			},
+			free:     enough,
+			expected: []string{"taint node-role.kubernetes.io/control-plane:NoSchedule"},
+		},
+		{
+			name: "unsupported arch and os",
+			node: v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{v1.LabelArchStable: "s390x", v1.LabelOSStable: "windows"}},
+				Status:     v1.NodeStatus{Conditions: ready},
+			},
+			free:     enough,
+			expected: []string{"arch s390x", "os windows"},
+		},
+		{
+			name:     "full node",
+			node:     v1.Node{Status: v1.NodeStatus{Conditions: ready}},
+			free:     v1.ResourceList{v1.ResourceCPU: resource.MustParse("50m"), v1.ResourceMemory: resource.MustParse("4Gi")},
+			expected: []string{"not enough cpu"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blockers := nodeBlockers(tt.node, tt.free, map[string]bool{"amd64": true})
+			if strings.Join(blockers, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected %q, got %q", tt.expected, blockers)
+			}
+		})
+	}
+}
+
+func TestRoleCapabilities(t *testing.T) {
+	tests := []struct {
+		name         string
+		rules        []rbacv1.PolicyRule
+		capabilities []string
+		wildcard     bool
+	}{
+		{
+			name: "backup only",
+			rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"actions.kio.kasten.io"}, Resources: []string{"backupactions"}, Verbs: []string{"get", "create"}},
+			},
+			capabilities: []string{"backup"},
+		},
+		{
+			name: "read only",
+			rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"actions.kio.kasten.io", "apps.kio.kasten.io"}, Resources: []string{"backupactions", "restoreactions", "restorepoints"}, Verbs: []string{"get", "list"}},
+			},
+		},
+		{
+			name: "every verb on the actions",
+			rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"actions.kio.kasten.io"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+			},
+			capabilities: []string{"backup", "restore"},
+			wildcard:     true,
+		},
+		{
+			name: "cluster admin",
+			rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+			},
+			capabilities: []string{"backup", "delete", "restore"},
+			wildcard:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps, wildcard := roleCapabilities(tt.rules)
+			var names []string
+			for name := range caps {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tt.capabilities, ",") || wildcard != tt.wildcard {
+				t.Errorf("expected %q wildcard %v, got %q wildcard %v", tt.capabilities, tt.wildcard, names, wildcard)
+			}
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		list     []string
+		found    bool
+		wildcard bool
+	}{
+		{list: []string{"get", "create"}, found: true},
+		{list: []string{"*"}, found: true, wildcard: true},
+		{list: []string{"*", "create"}, found: true},
+		{list: []string{"get"}},
+		{},
+	}
+	for _, tt := range tests {
+		found, wildcard := contains(tt.list, "create")
+		if found != tt.found || wildcard != tt.wildcard {
+			t.Errorf("expected %q to give %v %v, got %v %v", tt.list, tt.found, tt.wildcard, found, wildcard)
+		}
+	}
+}
+
+func TestServiceExposure(t *testing.T) {
+	tests := []struct {
+		name    string
+		service v1.Service
+		exposed bool
+		address string
+		tls     bool
+	}{
+		{
+			name:    "cluster ip",
+			service: v1.Service{Spec: v1.ServiceSpec{Type: v1.ServiceTypeClusterIP, Ports: []v1.ServicePort{{Port: 80}}}},
+		},
+		{
+			name: "load balancer on 443",
+			service: v1.Service{
+				Spec:   v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer, Ports: []v1.ServicePort{{Port: 443}}},
+				Status: v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "10.0.0.1"}}}},
+			},
+			exposed: true,
+			address: "10.0.0.1",
+			tls:     true,
+		},
+		{
+			name:    "pending load balancer on 80",
+			service: v1.Service{Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer, Ports: []v1.ServicePort{{Port: 80}}}},
+			exposed: true,
+			address: "<pending>",
+		},
+		{
+			name:    "node port",
+			service: v1.Service{Spec: v1.ServiceSpec{Type: v1.ServiceTypeNodePort, Ports: []v1.ServicePort{{Port: 8000, NodePort: 30080}}}},
+			exposed: true,
+			address: "<node>:30080",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := serviceExposure(tt.service)
+			if ok != tt.exposed {
+				t.Fatalf("expected exposed %v, got %v", tt.exposed, ok)
+			}
+			if ok && (e.address != tt.address || e.tls != tt.tls) {
+				t.Errorf("expected %s tls %v, got %s tls %v", tt.address, tt.tls, e.address, e.tls)
+			}
+		})
+	}
+}
+
+func TestServiceToGateway(t *testing.T) {
+	tests := []struct {
+		name    string
+		service v1.Service
+		gateway bool
+	}{
+		{name: "gateway service", service: v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "gateway"}}, gateway: true},
+		{name: "gateway selector", service: v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "k10-lb"}, Spec: v1.ServiceSpec{Selector: map[string]string{"service": "gateway"}}}, gateway: true},
+		{name: "gateway port", service: v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "k10-lb"}, Spec: v1.ServiceSpec{Ports: []v1.ServicePort{{Port: 443, TargetPort: intstr.FromInt(8000)}}}}, gateway: true},
+		{name: "prometheus", service: v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "prometheus-server"}, Spec: v1.ServiceSpec{Selector: map[string]string{"app": "prometheus"}, Ports: []v1.ServicePort{{Port: 80, TargetPort: intstr.FromInt(9090)}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if serviceToGateway(tt.service) != tt.gateway {
+				t.Errorf("expected to gateway %v", tt.gateway)
+			}
+		})
+	}
+}
+
+func TestIngressToGateway(t *testing.T) {
+	backend := func(service string) networkingv1.IngressBackend {
+		return networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: service}}
+	}
+	rule := func(service string) networkingv1.IngressRule {
+		return networkingv1.IngressRule{IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+			Paths: []networkingv1.HTTPIngressPath{{Path: "/", Backend: backend(service)}},
+		}}}
+	}
+	gatewayBackend := backend("gateway")
+	tests := []struct {
+		name    string
+		spec    networkingv1.IngressSpec
+		gateway bool
+	}{
+		{name: "default backend", spec: networkingv1.IngressSpec{DefaultBackend: &gatewayBackend}, gateway: true},
+		{name: "path backend", spec: networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{rule("grafana"), rule("gateway")}}, gateway: true},
+		{name: "other backends", spec: networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{rule("grafana"), {Host: "k10.example.com"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ingressToGateway(networkingv1.Ingress{Spec: tt.spec}) != tt.gateway {
+				t.Errorf("expected to gateway %v", tt.gateway)
+			}
+		})
+	}
+}
+
+// helmRelease returns a release of a chart with the defaults of Kasten which
+// raise no finding, config overrides them.
+func helmRelease(config map[string]interface{}) *release.Release {
+	defaults := map[string]interface{}{
+		"auth":       map[string]interface{}{"oidcAuth": map[string]interface{}{"enabled": true}},
+		"encryption": map[string]interface{}{"primaryKey": map[string]interface{}{"awsCmkKeyId": "arn:aws:kms:key"}},
+		"prometheus": map[string]interface{}{"server": map[string]interface{}{"enabled": true}},
+		"global": map[string]interface{}{"persistence": map[string]interface{}{
+			"enabled": true,
+			"catalog": map[string]interface{}{"size": "20Gi"},
+		}},
+		"limiter": map[string]interface{}{"csiSnapshots": int64(10)},
+		"garbagecollector": map[string]interface{}{
+			"daemonPeriod":   int64(21600),
+			"keepMaxActions": int64(1000),
+			"actions":        map[string]interface{}{"enabled": true},
+		},
+	}
+	return &release.Release{
+		Name:   "k10",
+		Chart:  &chart.Chart{Metadata: &chart.Metadata{Name: "k10", AppVersion: "8.5.0"}, Values: defaults},
+		Config: config,
+	}
+}
+
+func TestHelmValuesAudit(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		warnings  []string
+		criticals []string
+	}{
+		{
+			name: "chart defaults",
+		},
+		{
+			name:     "no authentication",
+			config:   map[string]interface{}{"auth": map[string]interface{}{"oidcAuth": map[string]interface{}{"enabled": false}}},
+			warnings: []string{"the dashboard has no authentication"},
+		},
+		{
+			name: "basic authentication",
+			config: map[string]interface{}{"auth": map[string]interface{}{
+				"oidcAuth":  map[string]interface{}{"enabled": false},
+				"basicAuth": map[string]interface{}{"enabled": true},
+			}},
+			warnings: []string{"the dashboard uses basic authentication"},
+		},
+		{
+			name: "ingress without TLS",
+			config: map[string]interface{}{"ingress": map[string]interface{}{
+				"create": true,
+				"tls":    map[string]interface{}{"enabled": false},
+			}},
+			warnings: []string{"the dashboard ingress has no TLS"},
+		},
+		{
+			name:     "passphrase primary key",
+			config:   map[string]interface{}{"encryption": map[string]interface{}{"primaryKey": map[string]interface{}{"awsCmkKeyId": ""}}},
+			warnings: []string{"the primary key is not managed by a KMS"},
+		},
+		{
+			name:     "prometheus disabled",
+			config:   map[string]interface{}{"prometheus": map[string]interface{}{"server": map[string]interface{}{"enabled": false}}},
+			warnings: []string{"prometheus is disabled"},
+		},
+		{
+			name:      "persistence disabled",
+			config:    map[string]interface{}{"global": map[string]interface{}{"persistence": map[string]interface{}{"enabled": false}}},
+			criticals: []string{"persistence is disabled"},
+		},
+		{
+			name:     "small catalog",
+			config:   map[string]interface{}{"global": map[string]interface{}{"persistence": map[string]interface{}{"catalog": map[string]interface{}{"size": "5Gi"}}}},
+			warnings: []string{"the catalog volume is 5Gi, below the recommended 20Gi"},
+		},
+		{
+			name:     "sidecar injected everywhere",
+			config:   map[string]interface{}{"injectKanisterSidecar": map[string]interface{}{"enabled": true}},
+			warnings: []string{"the kanister sidecar is injected in the workloads of every namespace"},
+		},
+		{
+			name:     "blocking limiter",
+			config:   map[string]interface{}{"limiter": map[string]interface{}{"csiSnapshots": int64(0)}},
+			warnings: []string{"limiter.csiSnapshots=0 blocks the operations it limits"},
+		},
+		{
+			name:     "limiter far from the default",
+			config:   map[string]interface{}{"limiter": map[string]interface{}{"csiSnapshots": int64(100)}},
+			warnings: []string{"limiter.csiSnapshots=100 is far from the chart default 10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestReport()
+			err := helmValuesAudit(r, helmRelease(tt.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			assertFindings(t, r, report.Critical, tt.criticals)
+		})
+	}
+}
+
+func TestGarbageCollectorAudit(t *testing.T) {
+	var actions []runtime.Object
+	for i := 0; i < 3; i++ {
+		actions = append(actions,
+			policyAction(backupActionFixture("app", fmt.Sprintf("daily-%d", i), "Complete", time.Duration(i)*24*time.Hour), "daily"),
+			backupActionFixture("app", fmt.Sprintf("manual-%d", i), "Complete", time.Duration(i)*time.Hour),
+		)
+	}
+	actions = append(actions, policyAction(backupActionFixture("db", "hourly-0", "Complete", time.Hour), "hourly"))
+	index, err := indexActions(context.TODO(), fakeDynamic(actions...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		warnings []string
+	}{
+		{
+			name: "actions under the limit",
+		},
+		{
+			name:     "actions of a policy above the limit",
+			config:   map[string]interface{}{"garbagecollector": map[string]interface{}{"keepMaxActions": int64(2)}},
+			warnings: []string{"3 backupactions of policy daily are kept while the garbage collector should keep 2"},
+		},
+		{
+			name:     "no limit",
+			config:   map[string]interface{}{"garbagecollector": map[string]interface{}{"keepMaxActions": int64(0)}},
+			warnings: []string{"garbagecollector.keepMaxActions is 0, the number of actions kept per policy is not limited"},
+		},
+		{
+			name:     "high limit",
+			config:   map[string]interface{}{"garbagecollector": map[string]interface{}{"keepMaxActions": int64(5000)}},
+			warnings: []string{"the garbage collector keeps 5000 actions per policy"},
+		},
+		{
+			name: "collectors disabled",
+			config: map[string]interface{}{"garbagecollector": map[string]interface{}{
+				"daemonPeriod": int64(172800),
+				"actions":      map[string]interface{}{"enabled": false},
+			}},
+			warnings: []string{"the garbage collector only runs every 172800 seconds", "the action collectors are disabled"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, out := newTestReport()
+			err := garbageCollectorAudit(r, helmRelease(tt.config), index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the actions of every namespace are counted
+			if !strings.Contains(out.String(), "There are 7 backupactions on the cluster") {
+				t.Errorf("expected the 7 backupactions to be counted: %s", out.String())
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+		})
+	}
+}
+
+// preferredDiscovery serves its resources as the preferred resources, the
+// fake discovery client returns none.
+type preferredDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d preferredDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return d.Resources, nil
+}
+
+// workloadDiscovery serves the deployments and statefulsets, and the
+// deploymentconfigs on OpenShift.
+func workloadDiscovery(openshift bool) preferredDiscovery {
+	resources := []*metav1.APIResourceList{{
+		GroupVersion: "apps/v1",
+		APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true}, {Name: "statefulsets", Namespaced: true}},
+	}}
+	if openshift {
+		resources = append(resources, &metav1.APIResourceList{
+			GroupVersion: "apps.openshift.io/v1",
+			APIResources: []metav1.APIResource{{Name: "deploymentconfigs", Namespaced: true}},
+		})
+	}
+	return preferredDiscovery{&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: resources}}}
+}
+
+// fakeMetadata returns a metadata client serving the objects.
+func fakeMetadata(objects ...runtime.Object) *metadatafake.FakeMetadataClient {
+	scheme := metadatafake.NewTestScheme()
+	if err := metav1.AddMetaToScheme(scheme); err != nil {
+		panic(err)
+	}
+	return metadatafake.NewSimpleMetadataClient(scheme, objects...)
+}
+
+// workloadMetadata is the metadata of a workload as the metadata client serves it.
+func workloadMetadata(apiVersion string, kind string, namespace string, name string, labels map[string]string, annotations map[string]string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels, Annotations: annotations},
+	}
+}
+
+func blueprintFixture(name string, actions ...string) *unstructured.Unstructured {
+	content := map[string]interface{}{}
+	for _, name := range actions {
+		content[name] = map[string]interface{}{"phases": []interface{}{map[string]interface{}{"func": "KubeTask", "name": name}}}
+	}
+	return kastenObject(blueprint.BlueprintResource, "Blueprint", testNamespace, name, map[string]interface{}{"actions": content})
+}
+
+func blueprintBindingFixture(name string, blueprintName string, label string) *unstructured.Unstructured {
+	return kastenObject(blueprintbinding.BlueprintBindingResource, "BlueprintBinding", testNamespace, name, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"blueprintRef": map[string]interface{}{"name": blueprintName, "namespace": testNamespace},
+			"resources": map[string]interface{}{
+				"matchAll": []interface{}{
+					map[string]interface{}{"type": map[string]interface{}{
+						"operator": "In",
+						"values":   []interface{}{map[string]interface{}{"group": "apps", "resource": "statefulsets"}},
+					}},
+					map[string]interface{}{"labels": map[string]interface{}{"key": "app", "operator": "In", "values": []interface{}{label}}},
+				},
+			},
+		},
+	})
+}
+
+func TestBlueprintsAudit(t *testing.T) {
+	postgres := workloadMetadata("apps/v1", "StatefulSet", "app", "postgres", map[string]string{"app": "postgres"}, nil)
+	tests := []struct {
+		name      string
+		kasten    []runtime.Object
+		workloads []runtime.Object
+		openshift bool
+		forbidden string
+		output    string
+		warnings  []string
+	}{
+		{
+			name:      "binding to a complete blueprint",
+			kasten:    []runtime.Object{blueprintFixture("postgres-bp", "backup", "restore", "delete"), blueprintBindingFixture("postgres", "postgres-bp", "postgres")},
+			workloads: []runtime.Object{postgres},
+			output:    "All workloads annotated with a blueprint refer to an existing blueprint",
+		},
+		{
+			name:     "blueprint without restore and delete",
+			kasten:   []runtime.Object{blueprintFixture("postgres-bp", "backup")},
+			warnings: []string{"blueprint kasten-io/postgres-bp has a backup action but no restore action", "blueprint kasten-io/postgres-bp has a backup action but no delete action"},
+		},
+		{
+			name:      "binding to a missing blueprint matching nothing",
+			kasten:    []runtime.Object{blueprintBindingFixture("mysql", "mysql-bp", "mysql")},
+			workloads: []runtime.Object{postgres},
+			warnings:  []string{"blueprintbinding mysql refers to blueprint kasten-io/mysql-bp which does not exist", "blueprintbinding mysql matches no resource"},
+		},
+		{
+			name:      "workload annotated with a missing blueprint",
+			workloads: []runtime.Object{workloadMetadata("apps/v1", "Deployment", "app", "api", nil, map[string]string{blueprintAnnotation: "gone"})},
+			warnings:  []string{"app/api (deployments) is annotated with blueprint gone which does not exist in kasten-io"},
+		},
+		{
+			name:      "deploymentconfigs outside of OpenShift",
+			workloads: []runtime.Object{postgres},
+			forbidden: "deploymentconfigs",
+			output:    "All workloads annotated with a blueprint refer to an existing blueprint",
+		},
+		{
+			name:      "deploymentconfigs forbidden on OpenShift",
+			workloads: []runtime.Object{postgres},
+			openshift: true,
+			forbidden: "deploymentconfigs",
+			output:    "insufficient permission to list deploymentconfigs.apps.openshift.io",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadataClient := fakeMetadata(tt.workloads...)
+			if tt.forbidden != "" {
+				metadataClient.PrependReactor("list", tt.forbidden, func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: tt.forbidden}, "", fmt.Errorf("no access"))
+				})
+			}
+
+			r, out := newTestReport()
+			err := blueprintsAudit(context.TODO(), r, fakeDynamic(tt.kasten...), metadataClient, workloadDiscovery(tt.openshift), testNamespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("expected the output to contain %q, got %s", tt.output, out.String())
+			}
+		})
+	}
+}
+
+func TestRBACManifest(t *testing.T) {
+	t.Setenv("KASTEN_HISTORY", "")
+	t.Setenv("KASTEN_NODE_PROXY", "")
+	manifest, err := permission.Manifest(serviceAccountName, "kasten-io", manifestPermissions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployed, err := os.ReadFile("../../deploy/rbac.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(manifest) != string(deployed) {
+		t.Errorf("deploy/rbac.yaml is not the output of the rbac command, regenerate it")
+	}
+	if strings.Contains(string(manifest), "nodes/proxy") {
+		t.Errorf("nodes/proxy is granted without KASTEN_NODE_PROXY")
+	}
+
+	t.Setenv("KASTEN_NODE_PROXY", "true")
+	manifest, err = permission.Manifest(serviceAccountName, "kasten-io", manifestPermissions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(manifest), "nodes/proxy") {
+		t.Errorf("nodes/proxy is not granted with KASTEN_NODE_PROXY=true")
+	}
+}
+
+// allowOnly answers the SelfSubjectAccessReviews, only the permissions given
+// are allowed.
+func allowOnly(corev1Client *fake.Clientset, permissions []permission.Permission) {
+	corev1Client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		for _, p := range permissions {
+			if p.Group == attributes.Group && p.Resource == attributes.Resource && p.Subresource == attributes.Subresource && p.Verb == attributes.Verb {
+				review.Status.Allowed = true
+			}
+		}
+		return true, review, nil
+	})
+}
+
+// fakeAuditor returns an auditor of a cluster without nodes on which only the
+// cluster information can be read.
+func fakeAuditor(r *report.Report) *auditor {
+	corev1Client := fake.NewSimpleClientset()
+	corev1Client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{Major: "1", Minor: "29", GitVersion: "v1.29.3"}
+	allowOnly(corev1Client, clusterInfoPermissions)
+	return &auditor{
+		r:               r,
+		kastenNamespace: testNamespace,
+		corev1Client:    corev1Client,
+		discoveryClient: corev1Client.Discovery(),
+		dynamicClient:   fakeDynamic(),
+	}
+}
+
+func TestAuditorRun(t *testing.T) {
+	r, out := newTestReport()
+	a := fakeAuditor(r)
+	err := a.run(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the cluster information ran, every other check is skipped with
+	// a warning per missing permission
+	if a.cluster.kubernetesVersion != "v1.29.3" {
+		t.Errorf("expected the cluster information check to run, got %+v", a.cluster)
+	}
+	var expected []string
+	for _, c := range checks[1:] {
+		skipped := false
+		for _, p := range c.permissions {
+			if !p.Optional && p.Resource != "nodes" {
+				expected = append(expected, "insufficient permission, the check is skipped: "+p.String())
+				skipped = true
+			}
+		}
+		if !skipped {
+			t.Fatalf("check %s needs another permission than list nodes for the test", c.title)
+		}
+		if !strings.Contains(out.String(), c.title) {
+			t.Errorf("expected the skipped check %s to be shown", c.title)
+		}
+	}
+	assertFindings(t, r, report.Warning, expected)
+	assertFindings(t, r, report.Critical, nil)
+}
+
+func kastenPVC(name string, size string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Resources: v1.VolumeResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)}},
+		},
+	}
+}
+
+// mountingPod is a kasten pod on node-1 mounting the claims.
+func mountingPod(name string, claims ...string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name}, Spec: v1.PodSpec{NodeName: "node-1"}}
+	for _, claim := range claims {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+			Name:         claim,
+			VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+		})
+	}
+	return pod
+}
+
+// statsSummary is the kubelet stats/summary of the kasten PVCs, the used
+// and capacity bytes of each claim.
+func statsSummary(usage map[string][2]int64) []byte {
+	var volumes []interface{}
+	for claim, stats := range usage {
+		volumes = append(volumes, map[string]interface{}{
+			"pvcRef":        map[string]interface{}{"name": claim, "namespace": testNamespace},
+			"usedBytes":     stats[0],
+			"capacityBytes": stats[1],
+		})
+	}
+	data, _ := json.Marshal(map[string]interface{}{"pods": []interface{}{map[string]interface{}{"volume": volumes}}})
+	return data
+}
+
+func TestKastenStorageAudit(t *testing.T) {
+	restorePoints := []runtime.Object{
+		workloadMetadata(restorepoint.SchemeGroupVersion.String(), "RestorePointContent", "", "rpc-1", nil, nil),
+		workloadMetadata(restorepoint.SchemeGroupVersion.String(), "RestorePointContent", "", "rpc-2", nil, nil),
+	}
+	tests := []struct {
+		name      string
+		pvcs      []runtime.Object
+		pods      []runtime.Object
+		usage     map[string][2]int64
+		forbidden bool
+		env       map[string]string
+		output    string
+		warnings  []string
+		criticals []string
+	}{
+		{
+			name:     "no PVC",
+			warnings: []string{"there is no PVC in kasten-io, Kasten runs without persistence"},
+		},
+		{
+			name:   "healthy PVCs",
+			pvcs:   []runtime.Object{kastenPVC(catalogClaim, "20Gi"), kastenPVC("jobs-pv-claim", "20Gi")},
+			pods:   []runtime.Object{mountingPod("catalog-svc-0", catalogClaim, "jobs-pv-claim")},
+			usage:  map[string][2]int64{catalogClaim: {10, 100}, "jobs-pv-claim": {50, 100}},
+			output: "2 restore points found, the recommended catalog size is 20Gi",
+		},
+		{
+			name:      "full PVCs",
+			pvcs:      []runtime.Object{kastenPVC(catalogClaim, "20Gi"), kastenPVC("jobs-pv-claim", "20Gi"), kastenPVC("logging-pv-claim", "20Gi")},
+			pods:      []runtime.Object{mountingPod("catalog-svc-0", catalogClaim, "jobs-pv-claim", "logging-pv-claim")},
+			usage:     map[string][2]int64{catalogClaim: {90, 100}, "jobs-pv-claim": {85, 100}, "logging-pv-claim": {10, 100}},
+			warnings:  []string{"PVC jobs-pv-claim is 85% full"},
+			criticals: []string{"PVC catalog-pv-claim is 90% full"},
+		},
+		{
+			name:   "unknown usage with a negative threshold",
+			pvcs:   []runtime.Object{kastenPVC(catalogClaim, "20Gi")},
+			pods:   []runtime.Object{mountingPod("catalog-svc-0", catalogClaim)},
+			env:    map[string]string{"KASTEN_PVC_USAGE_THRESHOLD": "-1"},
+			output: catalogClaim,
+		},
+		{
+			name:     "zero threshold",
+			pvcs:     []runtime.Object{kastenPVC(catalogClaim, "20Gi"), kastenPVC("jobs-pv-claim", "20Gi")},
+			pods:     []runtime.Object{mountingPod("catalog-svc-0", catalogClaim, "jobs-pv-claim")},
+			usage:    map[string][2]int64{catalogClaim: {10, 100}, "jobs-pv-claim": {85, 100}},
+			env:      map[string]string{"KASTEN_PVC_USAGE_THRESHOLD": "0"},
+			warnings: []string{"PVC jobs-pv-claim is 85% full"},
+		},
+		{
+			name:      "node proxy forbidden",
+			pvcs:      []runtime.Object{kastenPVC(catalogClaim, "20Gi")},
+			pods:      []runtime.Object{mountingPod("catalog-svc-0", catalogClaim)},
+			forbidden: true,
+			output:    "kubelet volume stats are not reachable through the node proxy",
+		},
+		{
+			name:     "catalog too small for the restore points",
+			pvcs:     []runtime.Object{kastenPVC(catalogClaim, "20Gi")},
+			env:      map[string]string{"KASTEN_CATALOG_SIZE_PER_RESTORE_POINT": "15Gi"},
+			warnings: []string{"the catalog PVC is 20Gi, below the 30Gi recommended for 2 restore points"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			kubeletStats = func(ctx context.Context, corev1Client kubernetes.Interface, node string) ([]byte, error) {
+				if tt.forbidden {
+					return nil, apierrors.NewForbidden(schema.GroupResource{Resource: "nodes/proxy"}, node, fmt.Errorf("no access"))
+				}
+				return statsSummary(tt.usage), nil
+			}
+			defer func() { kubeletStats = defaultKubeletStats }()
+
+			r, out := newTestReport()
+			corev1Client := fake.NewSimpleClientset(append(tt.pvcs, tt.pods...)...)
+			err := kastenStorageAudit(context.TODO(), r, corev1Client, fakeMetadata(restorePoints...), testNamespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			assertFindings(t, r, report.Critical, tt.criticals)
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("expected the output to contain %q, got %s", tt.output, out.String())
+			}
+		})
+	}
+}
+
+func TestKastenSupportAudit(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		cluster   clusterFacts
+		output    string
+		warnings  []string
+		criticals []string
+	}{
+		{
+			name:    "latest release",
+			version: "8.5.2",
+			cluster: clusterFacts{kubernetesVersion: "v1.31.4"},
+			output:  "Kasten is on the latest release",
+		},
+		{
+			name:      "out of support release",
+			version:   "7.0.14",
+			cluster:   clusterFacts{kubernetesVersion: "v1.29.3"},
+			warnings:  []string{"kasten 7.0 is 3 releases behind the latest 8.5"},
+			criticals: []string{"kasten 7.0 is out of support since 2025-06-30"},
+		},
+		{
+			name:      "release older than the data file",
+			version:   "5.5.8",
+			criticals: []string{"kasten 5.5.8 is older than 6.0, the oldest release of the releases data file, it is out of support"},
+		},
+		{
+			name:     "release newer than the data file",
+			version:  "9.0.1",
+			warnings: []string{"kasten 9.0.1 is not in the releases data file (latest known 8.5)"},
+		},
+		{
+			name:     "untested OpenShift",
+			version:  "8.5.2",
+			cluster:  clusterFacts{kubernetesVersion: "v1.27.8", openshiftVersion: "4.12.30"},
+			warnings: []string{"kasten 8.5 is not tested on OpenShift 4.12.30, tested from 4.15 to 4.19"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, out := newTestReport()
+			err := kastenSupportAudit(r, tt.version, tt.cluster)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			assertFindings(t, r, report.Critical, tt.criticals)
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("expected the output to contain %q, got %s", tt.output, out.String())
+			}
+		})
+	}
+}
+
+func TestFleetAudit(t *testing.T) {
+	contextAuditor = func(kubeconfig string, kubeContext string, r *report.Report, kastenNamespace string, kastenRelease string) (*auditor, error) {
+		if kubeContext == "gone" {
+			return nil, fmt.Errorf("context %q does not exist", kubeContext)
+		}
+		return fakeAuditor(r), nil
+	}
+	defer func() { contextAuditor = defaultContextAuditor }()
+
+	var out bytes.Buffer
+	fleetAudit(context.TODO(), &out, "", []string{"prod", "gone"}, testNamespace, "k10")
+	output := out.String()
+	for _, expected := range []string{
+		"Cluster prod",
+		"Kubernetes version : 1.29",
+		"Cluster gone",
+		`the audit of gone failed: context "gone" does not exist`,
+		"Fleet summary",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected the output to contain %q, got %s", expected, output)
+		}
+	}
+	if strings.Index(output, "Cluster prod") > strings.Index(output, "Cluster gone") {
+		t.Errorf("expected the clusters in the order of the contexts, got %s", output)
+	}
+	summary := output[strings.Index(output, "Fleet summary"):]
+	for _, line := range strings.Split(summary, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "prod":
+			if fields[len(fields)-1] == "failed" {
+				t.Errorf("expected prod to be audited, got %s", line)
+			}
+		case "gone":
+			if fields[len(fields)-1] != "failed" {
+				t.Errorf("expected gone to be failed, got %s", line)
+			}
+		}
+	}
+}
+
+func TestFleetSummary(t *testing.T) {
+	audited := &clusterAudit{context: "prod"}
+	audited.r = report.New(&audited.out)
+	audited.r.Summary.KastenVersion = "8.5.2"
+	audited.r.Summary.UnprotectedNamespaces = 2
+	audited.r.Summary.WorstRPO = 50 * time.Hour
+	audited.r.Summary.Immutable = true
+	audited.r.Critical("the license expired")
+	audited.r.Warning("there is no immutable profile")
+	audited.r.Warning("prometheus is disabled")
+	failed := &clusterAudit{context: "staging", err: fmt.Errorf("unreachable")}
+
+	r, out := newTestReport()
+	fleetSummary(r, []*clusterAudit{audited, failed})
+	expected := [][]string{
+		{"prod", "8.5.2", "2", "2d2h", "yes", "1", "2"},
+		{"staging", "-", "-", "-", "-", "-", "failed"},
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for _, fields := range expected {
+		found := false
+		for _, line := range lines {
+			if strings.Join(strings.Fields(line), " ") == strings.Join(fields, " ") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected a summary line %q, got %s", fields, out.String())
+		}
+	}
+}
+
+func mcmObject(gvr schema.GroupVersionResource, kind string, name string, labels map[string]string, content map[string]interface{}) *unstructured.Unstructured {
+	object := kastenObject(gvr, kind, defaultMultiClusterNamespace, name, content)
+	object.SetLabels(labels)
+	return object
+}
+
+func clusterFixture(name string, primary bool, connected string) *unstructured.Unstructured {
+	return mcmObject(clusterResource, "Cluster", name, map[string]string{allClustersLabel: "secondary", "env": name}, map[string]interface{}{
+		"spec": map[string]interface{}{"primary": primary},
+		"status": map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Connected", "status": connected, "message": "kubeconfig expired"},
+		}},
+	})
+}
+
+// distributionFixture distributes the policy to the clusters matching the
+// selector, status is its state and the state on each cluster.
+func distributionFixture(name string, selector map[string]interface{}, policyName string, status map[string]interface{}) *unstructured.Unstructured {
+	return mcmObject(distributionResource, "Distribution", name, nil, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"clusters":  []interface{}{selector},
+			"resources": []interface{}{map[string]interface{}{"resource": "policies", "name": policyName}},
+		},
+		"status": status,
+	})
+}
+
+func globalPolicy(name string) *unstructured.Unstructured {
+	return kastenObject(policy.PolicyResource, "Policy", defaultMultiClusterNamespace, name, map[string]interface{}{"spec": map[string]interface{}{"frequency": "@daily"}})
+}
+
+func TestMultiClusterAudit(t *testing.T) {
+	all := map[string]interface{}{allClustersLabel: "all"}
+	synced := map[string]interface{}{"state": "Succeeded", "clusters": []interface{}{map[string]interface{}{"name": "dev", "state": "Synced"}}}
+	tests := []struct {
+		name     string
+		primary  bool
+		objects  []runtime.Object
+		output   string
+		warnings []string
+	}{
+		{
+			name:   "not a primary",
+			output: "This cluster is not a Kasten multi-cluster primary",
+		},
+		{
+			name:    "no joined cluster",
+			primary: true,
+			output:  "The multi-cluster manager is installed but no cluster is joined",
+		},
+		{
+			name:    "healthy primary and secondary",
+			primary: true,
+			objects: []runtime.Object{
+				clusterFixture("primary", true, "True"),
+				clusterFixture("dev", false, "True"),
+				distributionFixture("daily", all, "daily", synced),
+				globalPolicy("daily"),
+			},
+			output: "policies/daily",
+		},
+		{
+			name:    "no primary",
+			primary: true,
+			objects: []runtime.Object{
+				clusterFixture("dev", false, "True"),
+				distributionFixture("daily", all, "daily", synced),
+				globalPolicy("daily"),
+			},
+			warnings: []string{"no cluster of kasten-io-mc is the primary"},
+		},
+		{
+			name:    "disconnected secondary without distribution",
+			primary: true,
+			objects: []runtime.Object{
+				clusterFixture("primary", true, "True"),
+				clusterFixture("dev", false, "False"),
+				distributionFixture("prod-only", map[string]interface{}{"env": "prod"}, "daily", nil),
+				globalPolicy("daily"),
+			},
+			warnings: []string{"secondary cluster dev is NotConnected: kubeconfig expired", "secondary cluster dev receives no global policy or profile"},
+		},
+		{
+			name:    "failed distribution of a missing policy",
+			primary: true,
+			objects: []runtime.Object{
+				clusterFixture("primary", true, "True"),
+				clusterFixture("dev", false, "True"),
+				distributionFixture("daily", all, "daily", map[string]interface{}{"state": "Failed", "error": "policy daily not found"}),
+			},
+			warnings: []string{"distribution daily is Failed: policy daily not found", "distribution daily distributes policies daily which does not exist in kasten-io-mc"},
+		},
+		{
+			name:    "drifted distribution",
+			primary: true,
+			objects: []runtime.Object{
+				clusterFixture("primary", true, "True"),
+				clusterFixture("dev", false, "True"),
+				distributionFixture("daily", all, "daily", map[string]interface{}{"state": "Succeeded", "clusters": []interface{}{
+					map[string]interface{}{"name": "dev", "state": "OutOfSync", "error": "policy daily was edited"},
+				}}),
+				globalPolicy("daily"),
+			},
+			warnings: []string{"distribution daily has drifted on cluster dev (OutOfSync policy daily was edited)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discoveryClient := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+			if tt.primary {
+				discoveryClient.Resources = []*metav1.APIResourceList{{GroupVersion: clusterResource.GroupVersion().String()}}
+			}
+
+			r, out := newTestReport()
+			err := multiClusterAudit(context.TODO(), r, fakeDynamic(tt.objects...), discoveryClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("expected the output to contain %q, got %s", tt.output, out.String())
+			}
+		})
+	}
+}
+
+func TestObjectStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  map[string]interface{}
+		state   string
+		message string
+	}{
+		{name: "no status", state: "Unknown"},
+		{
+			name:   "ready condition",
+			status: map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}}},
+			state:  "Ready",
+		},
+		{
+			name: "not connected condition",
+			status: map[string]interface{}{"conditions": []interface{}{
+				map[string]interface{}{"type": "Synced", "status": "True"},
+				map[string]interface{}{"type": "Connected", "status": "False", "message": "timeout"},
+			}},
+			state:   "NotConnected",
+			message: "timeout",
+		},
+		{
+			name:    "state",
+			status:  map[string]interface{}{"state": "Failed", "error": "denied"},
+			state:   "Failed",
+			message: "denied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object := unstructured.Unstructured{Object: map[string]interface{}{}}
+			if tt.status != nil {
+				object.Object["status"] = tt.status
+			}
+			state, message := objectStatus(object)
+			if state != tt.state || message != tt.message {
+				t.Errorf("expected %s %q, got %s %q", tt.state, tt.message, state, message)
+			}
+		})
+	}
+}
+
+func TestDistributionTargets(t *testing.T) {
+	cluster := unstructured.Unstructured{Object: map[string]interface{}{}}
+	cluster.SetLabels(map[string]string{"env": "prod", "region": "eu"})
+	tests := []struct {
+		name      string
+		selectors []interface{}
+		targets   bool
+	}{
+		{name: "no selector"},
+		{name: "all clusters", selectors: []interface{}{map[string]interface{}{allClustersLabel: "all"}}, targets: true},
+		{name: "matching labels", selectors: []interface{}{map[string]interface{}{"env": "prod", "region": "eu"}}, targets: true},
+		{name: "one label differs", selectors: []interface{}{map[string]interface{}{"env": "prod", "region": "us"}}},
+		{name: "second selector matches", selectors: []interface{}{map[string]interface{}{"env": "dev"}, map[string]interface{}{"region": "eu"}}, targets: true},
+		{name: "empty selector", selectors: []interface{}{map[string]interface{}{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distribution := unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{"clusters": tt.selectors}}}
+			if distributionTargets(distribution, cluster) != tt.targets {
+				t.Errorf("expected targets %v", tt.targets)
+			}
+		})
+	}
+}
+
+func TestDistributionDrifts(t *testing.T) {
+	distribution := unstructured.Unstructured{Object: map[string]interface{}{"status": map[string]interface{}{"clusters": []interface{}{
+		map[string]interface{}{"name": "dev", "state": "Synced"},
+		map[string]interface{}{"name": "prod", "state": "OutOfSync", "error": "edited"},
+		map[string]interface{}{"name": "new"},
+		map[string]interface{}{"name": "qa", "state": "Failed", "error": "denied"},
+	}}}}
+	drifts := distributionDrifts(distribution)
+	if strings.Join(drifts, ",") != "prod (OutOfSync edited),qa (Failed denied)" {
+		t.Errorf("unexpected drifts %q", drifts)
+	}
+}
+
+// licenseSecret is the license secret of Kasten holding the license, base64
+// encoded yaml, applied with kubectl.
+func licenseSecret(licenseYAML string) *v1.Secret {
+	encoded := base64.StdEncoding.EncodeToString([]byte(licenseYAML))
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testNamespace,
+			Name:      license.SecretName,
+			Annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": `{"apiVersion":"v1","kind":"Secret","data":{"license":"` + encoded + `"}}`,
+			},
+		},
+		Data: map[string][]byte{"license": []byte(encoded)},
+	}
+}
+
+// coreKinds are the kinds of the core resources fakeServer answers.
+var coreKinds = map[string]string{"nodes": "Node", "secrets": "Secret", "configmaps": "ConfigMap", "namespaces": "Namespace"}
+
+// fakeServer answers the requests of the checks over http from the fake
+// clientset, the version, the SelfSubjectAccessReviews and the core
+// resources, everything else is not found.
+func fakeServer(t *testing.T, corev1Client *fake.Clientset) *httptest.Server {
+	codec := scheme.Codecs.LegacyCodec(v1.SchemeGroupVersion, authorizationv1.SchemeGroupVersion)
+	write := func(w http.ResponseWriter, status int, object runtime.Object) {
+		raw, err := runtime.Encode(codec, object)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(raw)
+	}
+	notFound := func(w http.ResponseWriter, r *http.Request) {
+		status := apierrors.NewNotFound(schema.GroupResource{}, r.URL.Path).Status()
+		write(w, int(status.Code), &status)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/version" {
+			raw, _ := json.Marshal(corev1Client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(raw)
+			return
+		}
+		if r.URL.Path == "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews" {
+			review := &authorizationv1.SelfSubjectAccessReview{}
+			body, _ := io.ReadAll(r.Body)
+			if err := runtime.DecodeInto(codec, body, review); err != nil {
+				t.Error(err)
+			}
+			gvr := authorizationv1.SchemeGroupVersion.WithResource("selfsubjectaccessreviews")
+			answer, err := corev1Client.Invokes(k8stesting.NewRootCreateAction(gvr, review), nil)
+			if err != nil {
+				t.Error(err)
+			}
+			write(w, http.StatusCreated, answer)
+			return
+		}
+		// /api/v1/[namespaces/<namespace>/]<resource>[/<name>]
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+		if !strings.HasPrefix(r.URL.Path, "/api/v1/") || len(parts) > 4 {
+			notFound(w, r)
+			return
+		}
+		namespace := ""
+		if len(parts) > 2 && parts[0] == "namespaces" {
+			namespace, parts = parts[1], parts[2:]
+		}
+		kind, ok := coreKinds[parts[0]]
+		if !ok {
+			notFound(w, r)
+			return
+		}
+		gvr := v1.SchemeGroupVersion.WithResource(parts[0])
+		var action k8stesting.Action = k8stesting.NewListAction(gvr, v1.SchemeGroupVersion.WithKind(kind), namespace, metav1.ListOptions{})
+		if len(parts) == 2 {
+			action = k8stesting.NewGetAction(gvr, namespace, parts[1])
+		}
+		object, err := corev1Client.Invokes(action, nil)
+		if status, ok := err.(apierrors.APIStatus); ok {
+			s := status.Status()
+			write(w, int(s.Code), &s)
+			return
+		}
+		if err != nil {
+			t.Error(err)
+		}
+		write(w, http.StatusOK, object)
+	}))
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	var nodes []runtime.Object
+	for i := 0; i < 12; i++ {
+		nodes = append(nodes, &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%d", i)}})
+	}
+	corev1Client := fake.NewSimpleClientset(append(nodes, licenseSecret(`id: 0123-abcd
+customerName: Acme
+dateEnd: "2026-03-20T00:00:00Z"
+restrictions:
+  nodes: 10
+`))...)
+	corev1Client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{Major: "1", Minor: "29", GitVersion: "v1.29.3"}
+	allowOnly(corev1Client, permission.Join(clusterInfoPermissions, licensePermissions))
+	server := fakeServer(t, corev1Client)
+	defer server.Close()
+
+	collected, _ := newTestReport()
+	s, err := recordAudit(context.TODO(), &rest.Config{Host: server.URL}, collected, testNamespace, "k10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Error != "" {
+		t.Fatalf("the collection stopped on %s", s.Error)
+	}
+	var tarball bytes.Buffer
+	if err := s.Write(&tarball); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(tarball.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(recorded), license.SecretName) || strings.Contains(string(recorded), "last-applied-configuration") {
+		t.Error("expected the license secret in the snapshot without its last applied configuration")
+	}
+
+	// analyze, without the server
+	server.Close()
+	read, err := snapshot.Read(&tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	analyzed, _ := newTestReport()
+	if err := replayAudit(context.TODO(), analyzed, read); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFindings(t, collected, report.Critical, []string{"the cluster has 12 nodes, above the 10 nodes of the license"})
+	// the other checks are skipped, the licensing check read the secret
+	if !strings.Contains(strings.Join(messages(collected, report.Warning), "\n"), "the license expires in 18 days on 2026-03-20") {
+		t.Errorf("expected the license to expire soon, got %q", messages(collected, report.Warning))
+	}
+	if len(analyzed.Findings) != len(collected.Findings) {
+		t.Fatalf("expected the findings of the collection %q, got %q", collected.Findings, analyzed.Findings)
+	}
+	for i := range collected.Findings {
+		if analyzed.Findings[i] != collected.Findings[i] {
+			t.Errorf("expected finding %+v, got %+v", collected.Findings[i], analyzed.Findings[i])
+		}
+	}
+}
+
+func TestRecordAuditStopped(t *testing.T) {
+	corev1Client := fake.NewSimpleClientset()
+	corev1Client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{Major: "1", Minor: "29", GitVersion: "v1.29.3"}
+	allowOnly(corev1Client, clusterInfoPermissions)
+	corev1Client.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewInternalError(fmt.Errorf("etcd is down"))
+	})
+	server := fakeServer(t, corev1Client)
+	defer server.Close()
+
+	r, _ := newTestReport()
+	s, err := recordAudit(context.TODO(), &rest.Config{Host: server.URL}, r, testNamespace, "k10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the snapshot keeps what was read until the failure and the failure
+	if !strings.Contains(s.Error, "etcd is down") || len(s.Entries) == 0 {
+		t.Fatalf("expected the snapshot to record the failure, got %q and %d responses", s.Error, len(s.Entries))
+	}
+	server.Close()
+	err = replayAudit(context.TODO(), r, s)
+	if err == nil || !strings.Contains(err.Error(), "etcd is down") {
+		t.Errorf("expected the replay to stop on the same failure, got %v", err)
+	}
+}
+
+func TestLicenseAudit(t *testing.T) {
+	licenseYAML := func(dateEnd string, nodes int) string {
+		return fmt.Sprintf("id: 0123-abcd\ncustomerName: Acme\ndateEnd: %q\nrestrictions:\n  nodes: %d\n", dateEnd, nodes)
+	}
+	tests := []struct {
+		name      string
+		objects   []runtime.Object
+		nodes     int
+		warnings  []string
+		criticals []string
+	}{
+		{name: "free tier", nodes: 5},
+		{name: "free tier without the node count", nodes: 0},
+		{name: "free tier exceeded", nodes: 6, criticals: []string{"the cluster has 6 nodes, above the 5 nodes of the free tier"}},
+		{name: "valid license", objects: []runtime.Object{licenseSecret(licenseYAML("2027-01-01T00:00:00Z", 10))}, nodes: 10},
+		{name: "license without node limit", objects: []runtime.Object{licenseSecret(licenseYAML("2027-01-01T00:00:00Z", 0))}, nodes: 50},
+		{
+			name:      "node limit exceeded",
+			objects:   []runtime.Object{licenseSecret(licenseYAML("2027-01-01T00:00:00Z", 10))},
+			nodes:     11,
+			criticals: []string{"the cluster has 11 nodes, above the 10 nodes of the license"},
+		},
+		{
+			name:     "license expiring",
+			objects:  []runtime.Object{licenseSecret(licenseYAML("2026-03-20T00:00:00Z", 10))},
+			nodes:    3,
+			warnings: []string{"the license expires in 18 days on 2026-03-20"},
+		},
+		{
+			name:      "license expired",
+			objects:   []runtime.Object{licenseSecret(licenseYAML("2026-02-01T00:00:00Z", 10))},
+			nodes:     3,
+			criticals: []string{"the license expired on 2026-02-01"},
+		},
+		{
+			name: "license in a configmap",
+			objects: []runtime.Object{&v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: license.SecretName},
+				Data:       map[string]string{"license": licenseYAML("2027-01-01T00:00:00Z", 2)},
+			}},
+			nodes:     3,
+			criticals: []string{"the cluster has 3 nodes, above the 2 nodes of the license"},
+		},
+		{
+			name:     "no license key",
+			objects:  []runtime.Object{&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: license.SecretName}}},
+			nodes:    3,
+			warnings: []string{"the license k10-license has no license key, it is malformed"},
+		},
+		{
+			name:     "malformed license",
+			objects:  []runtime.Object{licenseSecret("{not a license")},
+			nodes:    3,
+			warnings: []string{"the license k10-license can't be decoded"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestReport()
+			err := licenseAudit(context.TODO(), r, fake.NewSimpleClientset(tt.objects...), testNamespace, tt.nodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			assertFindings(t, r, report.Critical, tt.criticals)
+		})
+	}
+}
+
+func TestDashboardAudit(t *testing.T) {
+	gatewayLB := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "gateway-ext"},
+		Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer, Selector: map[string]string{"service": "gateway"}, Ports: []v1.ServicePort{{Port: 80}}},
+		Status:     v1.ServiceStatus{LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "10.0.0.1"}}}},
+	}
+	gateway := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: "gateway"},
+		Spec:       v1.ServiceSpec{Type: v1.ServiceTypeClusterIP, Ports: []v1.ServicePort{{Port: 80}}},
+	}
+	ingress := func(name string, service string, tls bool) *networkingv1.Ingress {
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name},
+			Spec: networkingv1.IngressSpec{
+				DefaultBackend: &networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: service}},
+				Rules:          []networkingv1.IngressRule{{Host: name + ".example.com"}},
+			},
+		}
+		if tls {
+			ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{name + ".example.com"}}}
+		}
+		return ingress
+	}
+	route := func(name string, service string, termination string) *unstructured.Unstructured {
+		spec := map[string]interface{}{"host": name + ".apps.example.com", "to": map[string]interface{}{"name": service}}
+		if termination != "" {
+			spec["tls"] = map[string]interface{}{"termination": termination}
+		}
+		return kastenObject(routeResource, "Route", testNamespace, name, map[string]interface{}{"spec": spec})
+	}
+	noAuth := map[string]interface{}{"auth": map[string]interface{}{"oidcAuth": map[string]interface{}{"enabled": false}}}
+	tests := []struct {
+		name      string
+		config    map[string]interface{}
+		objects   []runtime.Object
+		routes    []runtime.Object
+		openshift bool
+		forbidden bool
+		warnings  []string
+		criticals []string
+	}{
+		{name: "not exposed", objects: []runtime.Object{gateway}},
+		{name: "ingress with TLS", objects: []runtime.Object{gateway, ingress("k10", "gateway", true)}},
+		{name: "ingress to another service", objects: []runtime.Object{gateway, ingress("grafana", "grafana", false)}},
+		{
+			name:     "ingress without TLS",
+			objects:  []runtime.Object{gateway, ingress("k10", "gateway", false)},
+			warnings: []string{"the dashboard is exposed without TLS through ingress k10 (k10.example.com)"},
+		},
+		{
+			name:      "load balancer without authentication",
+			config:    noAuth,
+			objects:   []runtime.Object{gateway, gatewayLB},
+			warnings:  []string{"the dashboard is exposed without TLS through service gateway-ext (10.0.0.1)"},
+			criticals: []string{"the dashboard is exposed without authentication through service gateway-ext (10.0.0.1)"},
+		},
+		{
+			name:      "routes on OpenShift",
+			objects:   []runtime.Object{gateway},
+			routes:    []runtime.Object{route("k10", "gateway", "edge"), route("k10-plain", "gateway", ""), route("grafana", "grafana", "")},
+			openshift: true,
+			warnings:  []string{"the dashboard is exposed without TLS through route k10-plain (k10-plain.apps.example.com)"},
+		},
+		{
+			name:      "routes forbidden",
+			objects:   []runtime.Object{gateway},
+			routes:    []runtime.Object{route("k10-plain", "gateway", "")},
+			openshift: true,
+			forbidden: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discoveryClient := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+			if tt.openshift {
+				discoveryClient.Resources = []*metav1.APIResourceList{{
+					GroupVersion: routeResource.GroupVersion().String(),
+					APIResources: []metav1.APIResource{{Name: routeResource.Resource, Namespaced: true}},
+				}}
+			}
+			dynamicClient := fakeDynamic(tt.routes...)
+			if tt.forbidden {
+				dynamicClient.PrependReactor("list", routeResource.Resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(routeResource.GroupResource(), "", fmt.Errorf("forbidden"))
+				})
+			}
+
+			r, out := newTestReport()
+			err := dashboardAudit(context.TODO(), r, fake.NewSimpleClientset(tt.objects...), dynamicClient, discoveryClient, helmRelease(tt.config), testNamespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			assertFindings(t, r, report.Critical, tt.criticals)
+			if tt.forbidden && !strings.Contains(out.String(), "the dashboard routes are not checked") {
+				t.Errorf("expected the routes to be reported as not checked: %s", out.String())
+			}
+		})
+	}
+}
+
+func TestDatabaseAudit(t *testing.T) {
+	template := func(image string) v1.PodTemplateSpec {
+		return v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{{Name: "db", Image: image}}}}
+	}
+	statefulSet := func(namespace string, name string, image string, annotations map[string]string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": name}, Annotations: annotations},
+			Spec:       appsv1.StatefulSetSpec{Template: template(image)},
+		}
+	}
+	deployment := func(namespace string, name string, image string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       appsv1.DeploymentSpec{Template: template(image)},
+		}
+	}
+	cnpgPod := func(namespace string, name string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"cnpg.io/cluster": "pg"}}}
+	}
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		kasten   []runtime.Object
+		warnings []string
+		shown    []string
+	}{
+		{
+			name:    "no database",
+			objects: []runtime.Object{deployment("web", "nginx", "nginx:1.25")},
+			shown:   []string{"No database workload found"},
+		},
+		{
+			name: "bound by annotation and binding",
+			objects: []runtime.Object{
+				statefulSet("app", "postgres", "postgres:16", map[string]string{blueprintAnnotation: "postgres-bp"}),
+				statefulSet("shop", "mysql", "mysql:8", nil),
+			},
+			kasten: []runtime.Object{blueprintBindingFixture("mysql-binding", "mysql-bp", "mysql")},
+			shown:  []string{"postgres-bp", "mysql-bp (binding mysql-binding)", "All database workloads are bound to a blueprint"},
+		},
+		{
+			name: "unbound with a successful backup",
+			objects: []runtime.Object{
+				deployment("cache", "redis", "bitnami/redis:7"),
+				statefulSet("app", "mongo", "mongo:7", nil),
+			},
+			kasten: []runtime.Object{
+				backupActionFixture("app", "backup-1", "Complete", time.Hour),
+				backupActionFixture("cache", "backup-1", "Failed", time.Hour),
+			},
+			warnings: []string{"statefulset app/mongo (MongoDB) has no blueprint, it is only protected by crash-consistent snapshots"},
+			shown:    []string{"deployment cache/redis (Redis) has no blueprint and no successful backup"},
+		},
+		{
+			name:     "CloudNativePG cluster counted once",
+			objects:  []runtime.Object{cnpgPod("app", "pg-1"), cnpgPod("app", "pg-2")},
+			kasten:   []runtime.Object{backupActionFixture("app", "backup-1", "Complete", time.Hour)},
+			warnings: []string{"cluster app/pg (PostgreSQL) has no blueprint"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamicClient := fakeDynamic(tt.kasten...)
+			index, err := indexActions(context.TODO(), dynamicClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, out := newTestReport()
+			err = databaseAudit(context.TODO(), r, fake.NewSimpleClientset(tt.objects...), dynamicClient, testNamespace, index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			assertFindings(t, r, report.Critical, nil)
+			for _, shown := range tt.shown {
+				if !strings.Contains(out.String(), shown) {
+					t.Errorf("expected %q in %s", shown, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestFindDatabases(t *testing.T) {
+	objects := []runtime.Object{
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "b", Name: "pg"},
+			Spec:       appsv1.StatefulSetSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{{Image: "postgres:16"}}}}},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "exporter"},
+			Spec:       appsv1.StatefulSetSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{{Image: "postgres-exporter:1"}}}}},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "mysql", Labels: map[string]string{"app.kubernetes.io/managed-by": "percona-server-mysql-operator"}},
+		},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "cluster-1", Labels: map[string]string{"cnpg.io/cluster": "cluster"}}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "a", Name: "web"}},
+	}
+	databases, err := findDatabases(context.TODO(), fake.NewSimpleClientset(objects...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, database := range databases {
+		found = append(found, fmt.Sprintf("%s %s/%s %s %s", database.Kind, database.Namespace, database.Name, database.Engine, database.Operator))
+	}
+	// sorted by namespace and name
+	expected := []string{"Cluster a/cluster PostgreSQL CloudNativePG", "Deployment a/mysql MySQL Percona", "StatefulSet b/pg PostgreSQL "}
+	if strings.Join(found, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %q, got %q", expected, found)
+	}
+}
+
+func TestNodeCapacityAudit(t *testing.T) {
+	node := func(name string, arch string, cpu string, conditions ...v1.NodeCondition) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{v1.LabelArchStable: arch, v1.LabelOSStable: "linux"}},
+			Status: v1.NodeStatus{
+				Allocatable: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse("8Gi")},
+				Conditions:  append(conditions, v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionTrue}),
+			},
+		}
+	}
+	pod := func(name string, nodeName string, cpu string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: name},
+			Spec: v1.PodSpec{NodeName: nodeName, Containers: []v1.Container{{
+				Name:      "app",
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}},
+			}}},
+		}
+	}
+	tests := []struct {
+		name      string
+		archs     string
+		objects   []runtime.Object
+		warnings  []string
+		criticals []string
+		shown     string
+	}{
+		{
+			name:    "room on a node",
+			objects: []runtime.Object{node("node-1", "amd64", "2"), pod("app-1", "node-1", "1")},
+			shown:   "Kasten workers can land on 1 of the 1 nodes",
+		},
+		{
+			name:      "every node full",
+			objects:   []runtime.Object{node("node-1", "amd64", "2"), pod("app-1", "node-1", "1"), pod("app-2", "node-1", "950m")},
+			criticals: []string{"no node can run the Kasten data mover and Kanister pods"},
+		},
+		{
+			name:    "pending pods are not counted",
+			objects: []runtime.Object{node("node-1", "amd64", "2"), pod("app-1", "", "2")},
+			shown:   "Kasten workers can land on 1 of the 1 nodes",
+		},
+		{
+			name:      "unsupported arch",
+			objects:   []runtime.Object{node("node-1", "arm64", "2")},
+			criticals: []string{"no node can run the Kasten data mover and Kanister pods"},
+		},
+		{
+			name:    "arch added to the supported archs",
+			archs:   "amd64, arm64",
+			objects: []runtime.Object{node("node-1", "arm64", "2")},
+			shown:   "Kasten workers can land on 1 of the 1 nodes",
+		},
+		{
+			name: "node under pressure",
+			objects: []runtime.Object{
+				node("node-1", "amd64", "2", v1.NodeCondition{Type: v1.NodeDiskPressure, Status: v1.ConditionTrue, Message: "disk is full"}),
+				node("node-2", "amd64", "2", v1.NodeCondition{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse}),
+			},
+			warnings: []string{"node node-1 has DiskPressure: disk is full"},
+			shown:    "Kasten workers can land on 2 of the 2 nodes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KASTEN_SUPPORTED_ARCHS", tt.archs)
+			r, out := newTestReport()
+			err := nodeCapacityAudit(context.TODO(), r, fake.NewSimpleClientset(tt.objects...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			assertFindings(t, r, report.Critical, tt.criticals)
+			if !strings.Contains(out.String(), tt.shown) {
+				t.Errorf("expected %q in %s", tt.shown, out.String())
+			}
+		})
+	}
+}
+
+func TestAddRequests(t *testing.T) {
+	container := func(cpu string, memory string) v1.Container {
+		return v1.Container{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse(memory),
+		}}}
+	}
+	pod := v1.Pod{Spec: v1.PodSpec{
+		Containers:     []v1.Container{container("100m", "512Mi"), container("200m", "512Mi")},
+		InitContainers: []v1.Container{container("500m", "256Mi")},
+	}}
+	total := v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}
+	addRequests(total, pod)
+	// the init container sets the cpu, the containers the memory
+	cpu, memory := total[v1.ResourceCPU], total[v1.ResourceMemory]
+	if cpu.String() != "1500m" || memory.String() != "1Gi" {
+		t.Errorf("expected 1500m and 1Gi, got %s and %s", cpu.String(), memory.String())
+	}
+}
+
+func TestRBACAudit(t *testing.T) {
+	rule := func(groups string, resources string, verbs ...string) rbacv1.PolicyRule {
+		return rbacv1.PolicyRule{APIGroups: []string{groups}, Resources: []string{resources}, Verbs: verbs}
+	}
+	clusterRole := func(name string, rules ...rbacv1.PolicyRule) *rbacv1.ClusterRole {
+		return &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}, Rules: rules}
+	}
+	roles := []runtime.Object{
+		clusterRole("cluster-admin", rule("*", "*", "*")),
+		clusterRole("k10-basic", rule("actions.kio.kasten.io", "backupactions", "create"), rule("actions.kio.kasten.io", "restoreactions", "create")),
+		clusterRole("view", rule("", "pods", "list")),
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "all"}, Rules: []rbacv1.PolicyRule{rule("*", "*", "*")}},
+	}
+	user := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "bob"}
+	clusterBinding := func(role string, subjects ...rbacv1.Subject) *rbacv1.ClusterRoleBinding {
+		return &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: role + "-binding"},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: role},
+			Subjects:   subjects,
+		}
+	}
+	binding := func(kind string, role string, subjects ...rbacv1.Subject) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: role + "-binding"},
+			RoleRef:    rbacv1.RoleRef{Kind: kind, Name: role},
+			Subjects:   subjects,
+		}
+	}
+	noSelfService := "no non admin user can manage the backups or restores of its namespaces"
+	tests := []struct {
+		name     string
+		bindings []runtime.Object
+		warnings []string
+	}{
+		{
+			name:     "only admins",
+			bindings: []runtime.Object{clusterBinding("cluster-admin", rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice"})},
+			warnings: []string{noSelfService},
+		},
+		{
+			name:     "k10-basic in a namespace",
+			bindings: []runtime.Object{binding("ClusterRole", "k10-basic", user)},
+		},
+		{
+			name:     "role without Kasten rights",
+			bindings: []runtime.Object{binding("ClusterRole", "view", user)},
+			warnings: []string{noSelfService},
+		},
+		{
+			name:     "restore in every namespace",
+			bindings: []runtime.Object{clusterBinding("k10-basic", user)},
+			warnings: []string{"User:bob is not an admin but can restore in every namespace (ClusterRole/k10-basic)", noSelfService},
+		},
+		{
+			name:     "wildcard role in a namespace",
+			bindings: []runtime.Object{binding("Role", "all", user)},
+			warnings: []string{"User:bob gets Kasten rights in namespace app through a wildcard rule (Role/app/all)"},
+		},
+		{
+			name: "kasten and kubernetes service accounts",
+			bindings: []runtime.Object{
+				clusterBinding("k10-basic", rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: testNamespace, Name: "k10-k10"}),
+				binding("ClusterRole", "k10-basic",
+					rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: "kube-system", Name: "controller"},
+					rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "system:serviceaccounts"},
+				),
+			},
+			warnings: []string{noSelfService},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, out := newTestReport()
+			err := rbacAudit(context.TODO(), r, fake.NewSimpleClientset(append(tt.bindings, roles...)...), testNamespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertFindings(t, r, report.Warning, tt.warnings)
+			assertFindings(t, r, report.Critical, nil)
+			if !strings.Contains(out.String(), "Kasten cluster role k10-basic exists") {
+				t.Errorf("expected the Kasten cluster roles to be listed: %s", out.String())
+			}
+		})
+	}
+}
+
+func TestRPOForNamespaces(t *testing.T) {
+	namespace := func(name string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	pvc := func(namespace string, name string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+	corev1Client := fake.NewSimpleClientset(
+		namespace("app"), namespace("db"), namespace("web"), namespace("empty"), namespace(testNamespace),
+		pvc("app", "data-1"), pvc("app", "data-2"), pvc("db", "data"), pvc(testNamespace, "catalog-pv-claim"),
+	)
+	index, err := indexActions(context.TODO(), fakeDynamic(
+		backupActionFixture("app", "backup-1", "Complete", 2*time.Hour),
+		backupActionFixture("app", "backup-2", "Failed", time.Hour),
+		backupActionFixture("web", "backup-1", "Failed", time.Hour),
+		backupActionFixture(testNamespace, "dr-1", "Complete", time.Hour),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, out := newTestReport()
+	namespacesWithPVCs, err := rpoForNamespaceWithPVC(context.TODO(), r, corev1Client, index, testNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(namespacesWithPVCs) != 3 || len(namespacesWithPVCs["app"]) != 2 {
+		t.Errorf("unexpected namespaces with PVCs %v", namespacesWithPVCs)
+	}
+	// the kasten namespace is left out
+	for _, shown := range []string{"app has 2 PVCs", "db has 1 PVCs", "No backupactions in namespace db", "4 PVCs and 2 backupactions scanned, 4 actions listed cluster wide"} {
+		if !strings.Contains(out.String(), shown) {
+			t.Errorf("expected %q in %s", shown, out.String())
+		}
+	}
+	if strings.Contains(out.String(), testNamespace+" has") {
+		t.Errorf("expected the kasten namespace to be left out: %s", out.String())
+	}
+	assertFindings(t, r, report.Warning, nil)
+
+	out.Reset()
+	err = rpoForNamespaceWithoutPVC(context.TODO(), r, corev1Client, index, namespacesWithPVCs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, shown := range []string{"web has no PVC", "empty has no PVC", "2 namespaces and 1 backupactions scanned"} {
+		if !strings.Contains(out.String(), shown) {
+			t.Errorf("expected %q in %s", shown, out.String())
+		}
+	}
+	assertFindings(t, r, report.Warning, []string{"no backupaction were successful in namespace web"})
+	if r.Summary.UnprotectedNamespaces != 3 || r.Summary.WorstRPO != 2*time.Hour {
+		t.Errorf("unexpected summary %+v", r.Summary)
+	}
+}
+
+func TestHistoryStore(t *testing.T) {
+	corev1Client := fake.NewSimpleClientset()
+	tests := []struct {
+		name     string
+		history  string
+		inPod    bool
+		expected history.Store
+	}{
+		{name: "outside of a pod", expected: history.DirStore{Path: defaultHistoryDir}},
+		{name: "in a pod", inPod: true},
+		{name: "none", history: "none"},
+		{name: "configmap", history: "configmap", inPod: true, expected: history.ConfigMapStore{Client: corev1Client, Namespace: testNamespace}},
+		{name: "mounted directory", history: "/var/audit", inPod: true, expected: history.DirStore{Path: "/var/audit"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KASTEN_HISTORY", tt.history)
+			t.Setenv("KUBERNETES_SERVICE_HOST", "")
+			if tt.inPod {
+				t.Setenv("KUBERNETES_SERVICE_HOST", "10.96.0.1")
+			}
+			store, err := historyStore(testNamespace, corev1Client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if store != tt.expected {
+				t.Errorf("expected %#v, got %#v", tt.expected, store)
+			}
+		})
+	}
+}
+
+func TestSaveRun(t *testing.T) {
+	t.Setenv("KASTEN_HISTORY_KEEP", "2")
+	defer func() { now = func() time.Time { return testNow } }()
+	store := history.DirStore{Path: t.TempDir()}
+	r, _ := newTestReport()
+	r.Warning("the license expires in %d days", 18)
+	for day := 0; day < 3; day++ {
+		runTime := testNow.Add(time.Duration(day) * 24 * time.Hour)
+		now = func() time.Time { return runTime }
+		if err := saveRun(context.TODO(), store, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the oldest run is pruned
+	names, err := store.List(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("expected 2 runs kept, got %q", names)
+	}
+	run, err := store.Load(context.TODO(), names[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !run.Time.Equal(testNow.Add(48*time.Hour)) || len(run.Findings) != 1 || run.Findings[0].Message != r.Findings[0].Message {
+		t.Errorf("unexpected last run %+v", run)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/michaelcourcy/audit-tool/pkg/blueprint"
+	"github.com/michaelcourcy/audit-tool/pkg/blueprintbinding"
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+)
+
+// defaultBindingTypes are the workloads a binding without type requirement
+// is evaluated against.
+var defaultBindingTypes = []blueprintbinding.ResourceType{
+	{Group: "apps", Resource: "deployments"},
+	{Group: "apps", Resource: "statefulsets"},
+	{Group: "apps.openshift.io", Resource: "deploymentconfigs"},
+}
+
+// blueprintsPermissions only covers the default types, the types targeted by
+// the bindings are checked when they are listed.
+var blueprintsPermissions = []permission.Permission{
+	{Group: blueprint.GroupName, Resource: "blueprints", Verb: "list"},
+	{Group: blueprintbinding.GroupName, Resource: "blueprintbindings", Verb: "list", KastenNamespace: true},
+	{Group: "apps", Resource: "deployments", Verb: "list"},
+	{Group: "apps", Resource: "statefulsets", Verb: "list"},
+	// the deploymentconfigs only exist on OpenShift
+	{Group: "apps.openshift.io", Resource: "deploymentconfigs", Verb: "list", Optional: true},
+}
+
+func blueprintsAudit(ctx context.Context, r *report.Report, dynamicClient dynamic.Interface, metadataClient metadata.Interface, discoveryClient discovery.DiscoveryInterface, kastenNamespace string) error {
+	r.Section("Blueprints and blueprint bindings")
+
+	blueprints := blueprint.BlueprintList{}
+	err := client.List(ctx, dynamicClient, blueprint.BlueprintResource, "", &blueprints)
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	if len(blueprints.Items) == 0 {
+		r.Println("  No blueprint found")
+	} else {
+		padding := "  %-40s %-20s %-60s \n"
+		r.Printf(padding, "BLUEPRINT", "NAMESPACE", "ACTIONS")
+		for _, bp := range blueprints.Items {
+			existing[bp.Namespace+"/"+bp.Name] = true
+			r.Printf(padding, bp.Name, bp.Namespace, strings.Join(bp.ActionNames(), ","))
+		}
+		for _, bp := range blueprints.Items {
+			if _, ok := bp.Actions["backup"]; !ok {
+				// hooks only blueprint, there is nothing to restore or delete
+				continue
+			}
+			for _, required := range []string{"restore", "delete"} {
+				if _, ok := bp.Actions[required]; !ok {
+					r.WarningFor(bp.Namespace+"/"+bp.Name+"/"+required, "blueprint %s/%s has a backup action but no %s action", bp.Namespace, bp.Name, required)
+				}
+			}
+		}
+	}
+
+	bindings := blueprintbinding.BlueprintBindingList{}
+	err = client.List(ctx, dynamicClient, blueprintbinding.BlueprintBindingResource, kastenNamespace, &bindings)
+	if err != nil {
+		return err
+	}
+
+	resources := newResourceLister(metadataClient, discoveryClient)
+	if len(bindings.Items) == 0 {
+		r.Println("  No blueprint binding found")
+	} else {
+		padding := "  %-40s %-40s %-10s \n"
+		r.Printf(padding, "BLUEPRINTBINDING", "BLUEPRINT", "MATCHES")
+		for _, binding := range bindings.Items {
+			types := bindingTypes(binding)
+			matches := 0
+			forbidden := false
+			for _, resourceType := range types {
+				candidates, err := resources.list(ctx, resourceType)
+				if errors.IsForbidden(err) {
+					forbidden = true
+					r.Printf("  insufficient permission to list %s.%s, blueprintbinding %s can't be fully evaluated \n", resourceType.Resource, resourceType.Group, binding.Name)
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				for _, candidate := range candidates {
+					if binding.Matches(candidate) {
+						matches++
+					}
+				}
+			}
+			r.Printf(padding, binding.Name, binding.Spec.BlueprintRef.Name, fmt.Sprint(matches))
+
+			blueprintNamespace := binding.Spec.BlueprintRef.Namespace
+			if blueprintNamespace == "" {
+				blueprintNamespace = kastenNamespace
+			}
+			if !existing[blueprintNamespace+"/"+binding.Spec.BlueprintRef.Name] {
+				r.WarningFor(binding.Name, "blueprintbinding %s refers to blueprint %s/%s which does not exist", binding.Name, blueprintNamespace, binding.Spec.BlueprintRef.Name)
+			}
+			if binding.Spec.Disabled {
+				r.Printf("  blueprintbinding %s is disabled \n", binding.Name)
+			} else if matches == 0 && !forbidden {
+				r.WarningFor(binding.Name, "blueprintbinding %s matches no resource", binding.Name)
+			}
+		}
+	}
+
+	annotatedInError := false
+	for _, resourceType := range defaultBindingTypes {
+		candidates, err := resources.list(ctx, resourceType)
+		if errors.IsForbidden(err) {
+			r.Printf("  insufficient permission to list %s.%s, their blueprint annotations are not checked \n", resourceType.Resource, resourceType.Group)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, candidate := range candidates {
+			name, ok := candidate.Annotations[blueprintAnnotation]
+			if ok && !existing[kastenNamespace+"/"+name] {
+				annotatedInError = true
+				r.WarningFor(candidate.Resource+"/"+candidate.Namespace+"/"+candidate.Name, "%s/%s (%s) is annotated with blueprint %s which does not exist in %s", candidate.Namespace, candidate.Name, candidate.Resource, name, kastenNamespace)
+			}
+		}
+	}
+	if !annotatedInError {
+		r.Println("  --> All workloads annotated with a blueprint refer to an existing blueprint")
+	}
+	return nil
+}
+
+// bindingTypes returns the resource types the binding can match, the types of
+// its "In" requirements or the default workloads.
+func bindingTypes(binding blueprintbinding.BlueprintBinding) []blueprintbinding.ResourceType {
+	var types []blueprintbinding.ResourceType
+	for _, requirements := range [][]blueprintbinding.ResourceRequirement{binding.Spec.Resources.MatchAll, binding.Spec.Resources.MatchAny} {
+		for _, requirement := range requirements {
+			if requirement.Type.Operator == blueprintbinding.OperatorIn {
+				types = append(types, requirement.Type.Values...)
+			}
+		}
+	}
+	if len(types) == 0 {
+		return defaultBindingTypes
+	}
+	return types
+}
+
+// resourceLister lists the metadata of any resource type across all
+// namespaces and keeps the result, several bindings often target the same type.
+type resourceLister struct {
+	metadataClient  metadata.Interface
+	discoveryClient discovery.DiscoveryInterface
+	versions        map[string]string
+	cache           map[string][]blueprintbinding.Resource
+}
+
+func newResourceLister(metadataClient metadata.Interface, discoveryClient discovery.DiscoveryInterface) *resourceLister {
+	return &resourceLister{
+		metadataClient:  metadataClient,
+		discoveryClient: discoveryClient,
+		cache:           map[string][]blueprintbinding.Resource{},
+	}
+}
+
+func (l *resourceLister) list(ctx context.Context, resourceType blueprintbinding.ResourceType) ([]blueprintbinding.Resource, error) {
+	key := resourceType.Group + "/" + resourceType.Resource
+	if resources, ok := l.cache[key]; ok {
+		return resources, nil
+	}
+	version, err := l.version(resourceType)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		// the resource is not served by this cluster
+		l.cache[key] = nil
+		return nil, nil
+	}
+	gvr := schema.GroupVersionResource{Group: resourceType.Group, Version: version, Resource: resourceType.Resource}
+	var resources []blueprintbinding.Resource
+	_, err = client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return l.metadataClient.Resource(gvr).List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		item := object.(*metav1.PartialObjectMetadata)
+		resources = append(resources, blueprintbinding.Resource{
+			Group:       resourceType.Group,
+			Resource:    resourceType.Resource,
+			Namespace:   item.Namespace,
+			Name:        item.Name,
+			Labels:      item.Labels,
+			Annotations: item.Annotations,
+		})
+		return nil
+	})
+	if errors.IsNotFound(err) {
+		// the resource was removed since the discovery
+		l.cache[key] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	l.cache[key] = resources
+	return resources, nil
+}
+
+// version returns the version of the resource type given by the binding or the
+// preferred version served by the cluster.
+func (l *resourceLister) version(resourceType blueprintbinding.ResourceType) (string, error) {
+	if resourceType.Version != "" {
+		return resourceType.Version, nil
+	}
+	if l.versions == nil {
+		lists, err := l.discoveryClient.ServerPreferredResources()
+		if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+			return "", err
+		}
+		l.versions = map[string]string{}
+		for _, list := range lists {
+			gv, err := schema.ParseGroupVersion(list.GroupVersion)
+			if err != nil {
+				continue
+			}
+			for _, resource := range list.APIResources {
+				l.versions[gv.Group+"/"+resource.Name] = gv.Version
+			}
+		}
+	}
+	return l.versions[resourceType.Group+"/"+resourceType.Resource], nil
+}
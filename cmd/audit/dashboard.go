@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"helm.sh/helm/v3/pkg/release"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+var routeResource = schema.GroupVersionResource{Group: "route.openshift.io", Version: "v1", Resource: "routes"}
+
+const (
+	// gatewayService is the service of the Kasten gateway serving the
+	// dashboard, its pods have the label service=gateway.
+	gatewayService = "gateway"
+	// gatewayPort is the port the gateway pods listen on.
+	gatewayPort = 8000
+)
+
+var dashboardPermissions = permission.Join(kastenInstallPermissions, []permission.Permission{
+	{Resource: "services", Verb: "list", KastenNamespace: true},
+	{Group: "networking.k8s.io", Resource: "ingresses", Verb: "list", KastenNamespace: true},
+	// the routes only exist on OpenShift
+	{Group: routeResource.Group, Resource: routeResource.Resource, Verb: "list", KastenNamespace: true, Optional: true},
+})
+
+// exposure is a way to reach a service of the kasten namespace from outside
+// of the cluster.
+type exposure struct {
+	kind    string
+	name    string
+	address string
+	tls     bool
+}
+
+func dashboardAudit(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface, dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface, release *release.Release, kastenNamespace string) error {
+	r.Section("Dashboard exposure")
+
+	values, err := helmValues(release)
+	if err != nil {
+		return err
+	}
+	auth := authMethod(values)
+	r.Printf("  The dashboard is protected by the authentication method: %s \n", auth)
+
+	var exposures []exposure
+	_, err = client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.CoreV1().Services(kastenNamespace).List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		service := object.(*v1.Service)
+		if !serviceToGateway(*service) {
+			return nil
+		}
+		if e, ok := serviceExposure(*service); ok {
+			exposures = append(exposures, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.NetworkingV1().Ingresses(kastenNamespace).List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		ingress := *object.(*networkingv1.Ingress)
+		if !ingressToGateway(ingress) {
+			return nil
+		}
+		var hosts []string
+		for _, rule := range ingress.Spec.Rules {
+			if rule.Host != "" {
+				hosts = append(hosts, rule.Host)
+			}
+		}
+		for _, lb := range ingress.Status.LoadBalancer.Ingress {
+			if len(hosts) == 0 {
+				hosts = append(hosts, lb.IP+lb.Hostname)
+			}
+		}
+		exposures = append(exposures, exposure{kind: "Ingress", name: ingress.Name, address: strings.Join(hosts, ","), tls: len(ingress.Spec.TLS) > 0})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = discoveryClient.ServerResourcesForGroupVersion(routeResource.GroupVersion().String())
+	if err == nil {
+		routes, err := dynamicClient.Resource(routeResource).Namespace(kastenNamespace).List(ctx, metav1.ListOptions{})
+		if errors.IsForbidden(err) {
+			r.Printf("  The routes of %s can't be listed, the dashboard routes are not checked \n", kastenNamespace)
+			routes = &unstructured.UnstructuredList{}
+		} else if err != nil {
+			return err
+		}
+		for _, route := range routes.Items {
+			if to, _, _ := unstructured.NestedString(route.Object, "spec", "to", "name"); to != gatewayService {
+				continue
+			}
+			host, _, _ := unstructured.NestedString(route.Object, "spec", "host")
+			termination, _, _ := unstructured.NestedString(route.Object, "spec", "tls", "termination")
+			exposures = append(exposures, exposure{kind: "Route", name: route.GetName(), address: host, tls: termination != ""})
+		}
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	if len(exposures) == 0 {
+		r.Printf("  --> The dashboard is not exposed outside of the cluster \n")
+		return nil
+	}
+	padding := "  %-15s %-30s %-50s %-5s \n"
+	r.Printf(padding, "KIND", "NAME", "ADDRESS", "TLS")
+	for _, e := range exposures {
+		r.Printf(padding, e.kind, e.name, e.address, fmt.Sprint(e.tls))
+	}
+	for _, e := range exposures {
+		if auth == "none" {
+			r.CriticalFor(e.kind+"/"+e.name, "the dashboard is exposed without authentication through %s %s (%s)", strings.ToLower(e.kind), e.name, e.address)
+		}
+		if !e.tls {
+			r.WarningFor(e.kind+"/"+e.name, "the dashboard is exposed without TLS through %s %s (%s)", strings.ToLower(e.kind), e.name, e.address)
+		}
+	}
+	return nil
+}
+
+// serviceToGateway tells if the service sends its traffic to the gateway pods.
+func serviceToGateway(service v1.Service) bool {
+	if service.Name == gatewayService || service.Spec.Selector["service"] == gatewayService {
+		return true
+	}
+	for _, port := range service.Spec.Ports {
+		if port.TargetPort.IntValue() == gatewayPort {
+			return true
+		}
+	}
+	return false
+}
+
+// ingressToGateway tells if one of the backends of the ingress is the
+// gateway service.
+func ingressToGateway(ingress networkingv1.Ingress) bool {
+	toGateway := func(backend *networkingv1.IngressBackend) bool {
+		return backend != nil && backend.Service != nil && backend.Service.Name == gatewayService
+	}
+	if toGateway(ingress.Spec.DefaultBackend) {
+		return true
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if toGateway(&path.Backend) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serviceExposure returns how a LoadBalancer or NodePort service can be
+// reached, TLS is assumed when it only listens on 443.
+func serviceExposure(service v1.Service) (exposure, bool) {
+	e := exposure{kind: "Service", name: service.Name, tls: true}
+	var addresses []string
+	switch service.Spec.Type {
+	case v1.ServiceTypeLoadBalancer:
+		for _, lb := range service.Status.LoadBalancer.Ingress {
+			addresses = append(addresses, lb.IP+lb.Hostname)
+		}
+		if len(addresses) == 0 {
+			addresses = append(addresses, "<pending>")
+		}
+		for _, port := range service.Spec.Ports {
+			if port.Port != 443 {
+				e.tls = false
+			}
+		}
+	case v1.ServiceTypeNodePort:
+		for _, port := range service.Spec.Ports {
+			addresses = append(addresses, fmt.Sprintf("<node>:%d", port.NodePort))
+			if port.Port != 443 {
+				e.tls = false
+			}
+		}
+	default:
+		return e, false
+	}
+	e.address = strings.Join(addresses, ",")
+	return e, true
+}
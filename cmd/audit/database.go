@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/michaelcourcy/audit-tool/pkg/blueprintbinding"
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// blueprintAnnotation is the annotation Kasten reads on a workload to find the
+// blueprint it must use.
+const blueprintAnnotation = "kanister.kasten.io/blueprint"
+
+// databaseImages is evaluated in order, mongo must come before percona-server
+// because of percona-server-mongodb.
+var databaseImages = []struct {
+	engine   string
+	keywords []string
+}{
+	{"MongoDB", []string{"mongo"}},
+	{"PostgreSQL", []string{"postgres", "postgis", "timescaledb"}},
+	{"MariaDB", []string{"mariadb"}},
+	{"MySQL", []string{"mysql", "percona-server", "percona-xtradb-cluster"}},
+	{"Cassandra", []string{"cassandra"}},
+	{"Elasticsearch", []string{"elasticsearch"}},
+	{"Redis", []string{"redis"}},
+}
+
+// databaseOperators are the labels the well known operators put on the
+// workloads they manage, an empty value matches any value.
+var databaseOperators = []struct {
+	label    string
+	value    string
+	engine   string
+	operator string
+}{
+	{"cnpg.io/cluster", "", "PostgreSQL", "CloudNativePG"},
+	{"postgres-operator.crunchydata.com/cluster", "", "PostgreSQL", "Crunchy"},
+	{"app.kubernetes.io/managed-by", "percona-postgresql-operator", "PostgreSQL", "Percona"},
+	{"app.kubernetes.io/managed-by", "percona-server-mongodb-operator", "MongoDB", "Percona"},
+	{"app.kubernetes.io/managed-by", "percona-xtradb-cluster-operator", "MySQL", "Percona"},
+	{"app.kubernetes.io/managed-by", "percona-server-mysql-operator", "MySQL", "Percona"},
+	{"common.k8s.elastic.co/type", "elasticsearch", "Elasticsearch", "ECK"},
+}
+
+var databasePermissions = permission.Join([]permission.Permission{
+	{Group: "apps", Resource: "statefulsets", Verb: "list"},
+	{Group: "apps", Resource: "deployments", Verb: "list"},
+	{Resource: "pods", Verb: "list"},
+	{Group: blueprintbinding.GroupName, Resource: "blueprintbindings", Verb: "list", KastenNamespace: true},
+}, actionsPermissions)
+
+type databaseWorkload struct {
+	blueprintbinding.Resource
+	Kind     string
+	Engine   string
+	Operator string
+}
+
+func databaseAudit(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface, dynamicClient dynamic.Interface, kastenNamespace string, actions *actionIndex) error {
+	r.Section("Database workloads")
+
+	databases, err := findDatabases(ctx, corev1Client)
+	if err != nil {
+		return err
+	}
+	if len(databases) == 0 {
+		r.Println("  No database workload found")
+		return nil
+	}
+
+	bindings := blueprintbinding.BlueprintBindingList{}
+	err = client.List(ctx, dynamicClient, blueprintbinding.BlueprintBindingResource, kastenNamespace, &bindings)
+	if err != nil {
+		return err
+	}
+
+	padding := "  %-30s %-40s %-25s %-30s \n"
+	r.Printf(padding, "NAMESPACE", "WORKLOAD", "ENGINE", "BLUEPRINT")
+	var unbound []databaseWorkload
+	for _, database := range databases {
+		blueprint := database.Annotations[blueprintAnnotation]
+		if blueprint == "" {
+			for _, binding := range bindings.Items {
+				if binding.Matches(database.Resource) {
+					blueprint = binding.Spec.BlueprintRef.Name + " (binding " + binding.Name + ")"
+					break
+				}
+			}
+		}
+		engine := database.Engine
+		if database.Operator != "" {
+			engine = fmt.Sprintf("%s (%s)", database.Engine, database.Operator)
+		}
+		workload := fmt.Sprintf("%s/%s", database.Kind, database.Name)
+		if blueprint == "" {
+			unbound = append(unbound, database)
+			r.Printf(padding, database.Namespace, workload, engine, "-")
+		} else {
+			r.Printf(padding, database.Namespace, workload, engine, blueprint)
+		}
+	}
+
+	if len(unbound) == 0 {
+		r.Println("  --> All database workloads are bound to a blueprint")
+		return nil
+	}
+	for _, database := range unbound {
+		if hasCompleteBackupAction(actions.get(database.Namespace)) {
+			r.WarningFor(database.Kind+"/"+database.Namespace+"/"+database.Name, "%s %s/%s (%s) has no blueprint, it is only protected by crash-consistent snapshots", strings.ToLower(database.Kind), database.Namespace, database.Name, database.Engine)
+		} else {
+			r.Printf("  %s %s/%s (%s) has no blueprint and no successful backup \n", strings.ToLower(database.Kind), database.Namespace, database.Name, database.Engine)
+		}
+	}
+	return nil
+}
+
+// findDatabases returns the statefulsets and deployments running a database and
+// the CloudNativePG clusters, which manage their pods without a statefulset.
+func findDatabases(ctx context.Context, corev1Client kubernetes.Interface) ([]databaseWorkload, error) {
+	var databases []databaseWorkload
+
+	_, err := client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.AppsV1().StatefulSets("").List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		sts := object.(*appsv1.StatefulSet)
+		resource := blueprintbinding.Resource{Group: "apps", Resource: "statefulsets", Namespace: sts.Namespace, Name: sts.Name, Labels: sts.Labels, Annotations: sts.Annotations}
+		if database, ok := detectDatabase(resource, "StatefulSet", sts.Spec.Template); ok {
+			databases = append(databases, database)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.AppsV1().Deployments("").List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		deployment := object.(*appsv1.Deployment)
+		resource := blueprintbinding.Resource{Group: "apps", Resource: "deployments", Namespace: deployment.Namespace, Name: deployment.Name, Labels: deployment.Labels, Annotations: deployment.Annotations}
+		if database, ok := detectDatabase(resource, "Deployment", deployment.Spec.Template); ok {
+			databases = append(databases, database)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := map[string]bool{}
+	_, err = client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.CoreV1().Pods("").List(ctx, opts)
+	}, metav1.ListOptions{LabelSelector: "cnpg.io/cluster"}, func(object runtime.Object) error {
+		pod := object.(*v1.Pod)
+		name := pod.Labels["cnpg.io/cluster"]
+		if clusters[pod.Namespace+"/"+name] {
+			return nil
+		}
+		clusters[pod.Namespace+"/"+name] = true
+		databases = append(databases, databaseWorkload{
+			Resource: blueprintbinding.Resource{Group: "postgresql.cnpg.io", Resource: "clusters", Namespace: pod.Namespace, Name: name, Labels: pod.Labels},
+			Kind:     "Cluster",
+			Engine:   "PostgreSQL",
+			Operator: "CloudNativePG",
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(databases, func(i, j int) bool {
+		if databases[i].Namespace != databases[j].Namespace {
+			return databases[i].Namespace < databases[j].Namespace
+		}
+		return databases[i].Name < databases[j].Name
+	})
+	return databases, nil
+}
+
+func detectDatabase(resource blueprintbinding.Resource, kind string, template v1.PodTemplateSpec) (databaseWorkload, bool) {
+	database := databaseWorkload{Resource: resource, Kind: kind}
+	for _, labels := range []map[string]string{resource.Labels, template.Labels} {
+		for _, op := range databaseOperators {
+			value, ok := labels[op.label]
+			if ok && (op.value == "" || op.value == value) {
+				database.Engine = op.engine
+				database.Operator = op.operator
+				return database, true
+			}
+		}
+	}
+	for _, container := range template.Spec.Containers {
+		if engine := databaseEngine(imageName(container.Image)); engine != "" {
+			database.Engine = engine
+			return database, true
+		}
+	}
+	for _, labels := range []map[string]string{resource.Labels, template.Labels} {
+		for _, key := range []string{"app.kubernetes.io/name", "app"} {
+			if engine := databaseEngine(strings.ToLower(labels[key])); engine != "" {
+				database.Engine = engine
+				return database, true
+			}
+		}
+	}
+	return database, false
+}
+
+// databaseEngine ignores exporters and operators which often carry the name of
+// the database they serve.
+func databaseEngine(name string) string {
+	if name == "" || strings.Contains(name, "exporter") || strings.Contains(name, "operator") {
+		return ""
+	}
+	for _, candidate := range databaseImages {
+		for _, keyword := range candidate.keywords {
+			if strings.Contains(name, keyword) {
+				return candidate.engine
+			}
+		}
+	}
+	return ""
+}
+
+// imageName returns the last path element of an image reference without its
+// tag or digest, "docker.io/bitnami/postgresql:15@sha256:..." gives "postgresql".
+func imageName(image string) string {
+	image = strings.ToLower(image)
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, "/"); i >= 0 {
+		image = image[i+1:]
+	}
+	if i := strings.Index(image, ":"); i >= 0 {
+		image = image[:i]
+	}
+	return image
+}
+
+func hasCompleteBackupAction(actions namespaceActions) bool {
+	for _, backupAction := range actions.backups {
+		if backupAction.Status.State == "Complete" {
+			return true
+		}
+	}
+	return false
+}
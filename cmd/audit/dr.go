@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/michaelcourcy/audit-tool/pkg/action"
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/policy"
+	"github.com/michaelcourcy/audit-tool/pkg/profile"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// disasterRecoverySecret holds the passphrase needed to restore the catalog.
+	disasterRecoverySecret = "k10-dr-secret"
+	// policyNameLabel is set by Kasten on the actions run by a policy.
+	policyNameLabel = "k10.kasten.io/policyName"
+	// defaultDisasterRecoveryMaxAge is used when the frequency of the policy
+	// does not tell when a disaster recovery backup is stale.
+	defaultDisasterRecoveryMaxAge = 24 * time.Hour
+)
+
+var disasterRecoveryPermissions = []permission.Permission{
+	{Group: policy.GroupName, Resource: "policies", Verb: "list", KastenNamespace: true},
+	{Group: profile.GroupName, Resource: "profiles", Verb: "get", KastenNamespace: true},
+	{Resource: "secrets", Verb: "get", KastenNamespace: true},
+	{Group: action.GroupName, Resource: "backupactions", Verb: "list", KastenNamespace: true},
+}
+
+func disasterRecoveryAudit(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface, dynamicClient dynamic.Interface, kastenNamespace string) error {
+	r.Section("Disaster recovery")
+
+	policies := policy.PolicyList{}
+	err := client.List(ctx, dynamicClient, policy.PolicyResource, kastenNamespace, &policies)
+	if err != nil {
+		return err
+	}
+	var drPolicy *policy.Policy
+	for i := range policies.Items {
+		if policies.Items[i].IsDisasterRecovery() {
+			drPolicy = &policies.Items[i]
+			break
+		}
+	}
+	if drPolicy == nil {
+		r.Critical("disaster recovery is not enabled, the catalog of Kasten can't be recovered if the cluster is lost")
+		return nil
+	}
+	r.Printf("  Disaster recovery policy %s runs %s \n", drPolicy.Name, drPolicy.Spec.Frequency)
+	if drPolicy.Spec.Paused {
+		r.WarningFor(drPolicy.Name, "the disaster recovery policy %s is paused", drPolicy.Name)
+	}
+	if drPolicy.Status.Validation != "" && drPolicy.Status.Validation != "Success" {
+		r.CriticalFor(drPolicy.Name, "the disaster recovery policy %s is not valid", drPolicy.Name)
+	}
+
+	// profile
+	kdr := drPolicy.Spec.KdrSnapshotConfiguration
+	profileRef, ok := drPolicy.Profile()
+	if kdr != nil && !kdr.ExportCatalogSnapshot {
+		r.CriticalFor(drPolicy.Name, "the disaster recovery policy %s only takes a local snapshot of the catalog, it is not exported off-cluster", drPolicy.Name)
+	} else if !ok {
+		r.CriticalFor(drPolicy.Name, "the disaster recovery policy %s has no location profile", drPolicy.Name)
+	} else {
+		err = checkDisasterRecoveryProfile(ctx, r, dynamicClient, kastenNamespace, profileRef)
+		if err != nil {
+			return err
+		}
+	}
+
+	// passphrase
+	_, err = corev1Client.CoreV1().Secrets(kastenNamespace).Get(ctx, disasterRecoverySecret, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		r.Critical("the secret %s holding the disaster recovery passphrase does not exist in %s", disasterRecoverySecret, kastenNamespace)
+	} else if err != nil {
+		return err
+	} else {
+		r.Printf("  The disaster recovery passphrase secret %s exists \n", disasterRecoverySecret)
+	}
+
+	// last successful backup
+	actions := action.BackupActionList{}
+	err = client.List(ctx, dynamicClient, action.BackupActionResource, kastenNamespace, &actions)
+	if err != nil {
+		return err
+	}
+	var last time.Time
+	for _, backupAction := range actions.Items {
+		// the actions without the label are not run by the disaster
+		// recovery policy
+		if backupAction.Labels[policyNameLabel] != drPolicy.Name {
+			continue
+		}
+		if backupAction.Status.State == "Complete" && backupAction.Status.EndTime.After(last) {
+			last = backupAction.Status.EndTime
+		}
+	}
+	if last.IsZero() {
+		r.Critical("no disaster recovery backup was successful")
+		return nil
+	}
+	maxAge := defaultDisasterRecoveryMaxAge
+	if interval, ok := drPolicy.Interval(); ok {
+		maxAge = 2 * interval
+	}
+	age := now().Sub(last)
+	days := int64(age.Hours() / 24)
+	hours := int64(age.Hours()) % 24
+	if age > maxAge {
+		r.Critical("the last successful disaster recovery backup is %d days and %d hours old", days, hours)
+	} else {
+		r.Printf("  --> The last successful disaster recovery backup is %d days and %d hours old \n", days, hours)
+	}
+	return nil
+}
+
+// checkDisasterRecoveryProfile checks that the disaster recovery profile
+// exists, is outside of the cluster and is immutable.
+func checkDisasterRecoveryProfile(ctx context.Context, r *report.Report, dynamicClient dynamic.Interface, kastenNamespace string, profileRef policy.ObjectReference) error {
+	namespace := profileRef.Namespace
+	if namespace == "" {
+		namespace = kastenNamespace
+	}
+	drProfile := profile.Profile{}
+	err := client.Get(ctx, dynamicClient, profile.ProfileResource, namespace, profileRef.Name, &drProfile)
+	if errors.IsNotFound(err) {
+		r.CriticalFor(profileRef.Name, "the disaster recovery profile %s does not exist", profileRef.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	location := drProfile.Spec.LocationSpec.Location
+	r.Printf("  Disaster recovery exports to profile %s (%s) \n", drProfile.Name, location.LocationType)
+	if drProfile.Status.Validation != "Success" {
+		r.CriticalFor(drProfile.Name, "the disaster recovery profile %s is not valid", drProfile.Name)
+	}
+	switch location.LocationType {
+	case "ObjectStore":
+		if location.ObjectStore.ProtectionPeriod == "" {
+			r.WarningFor(drProfile.Name, "the disaster recovery profile %s is not immutable, the catalog is not protected against ransomware", drProfile.Name)
+		}
+	case "FileStore":
+		r.WarningFor(drProfile.Name, "the disaster recovery profile %s is a file store on claim %s, it may not be off-cluster", drProfile.Name, location.FileStore.ClaimName)
+	}
+	return nil
+}
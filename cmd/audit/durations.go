@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/policy"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// defaultDurationGrowth is the percentage the mean duration of the
+	// actions can grow from one week to the next before it is reported, it
+	// can be changed with KASTEN_DURATION_GROWTH.
+	defaultDurationGrowth = 50
+	// defaultIntervalRatio is the percentage of the policy interval an
+	// action can take before it is reported, it can be changed with
+	// KASTEN_INTERVAL_RATIO.
+	defaultIntervalRatio = 80
+	week                 = 7 * 24 * time.Hour
+)
+
+var durationsPermissions = permission.Join(actionsPermissions, []permission.Permission{
+	{Group: policy.GroupName, Resource: policy.PolicyResource.Resource, Verb: "list", KastenNamespace: true, Optional: true},
+})
+
+// timedAction is a complete action with its duration.
+type timedAction struct {
+	kind      string
+	namespace string
+	policy    string
+	name      string
+	end       time.Time
+	duration  time.Duration
+}
+
+// durationGroup is the durations of the actions of one namespace and kind,
+// or of one policy.
+type durationGroup struct {
+	name      string
+	kind      string
+	durations []time.Duration
+	// thisWeek and lastWeek are the durations of the actions which ended in
+	// the last 7 days and in the 7 days before
+	thisWeek []time.Duration
+	lastWeek []time.Duration
+	max      timedAction
+}
+
+func (group *durationGroup) add(a timedAction) {
+	group.durations = append(group.durations, a.duration)
+	age := now().Sub(a.end)
+	switch {
+	case age < week:
+		group.thisWeek = append(group.thisWeek, a.duration)
+		if a.duration > group.max.duration {
+			group.max = a
+		}
+	case age < 2*week:
+		group.lastWeek = append(group.lastWeek, a.duration)
+	}
+}
+
+// timedActions returns the complete backupactions and exportactions with
+// their duration.
+func timedActions(index *actionIndex) []timedAction {
+	var result []timedAction
+	for namespace, actions := range index.namespaces {
+		for i := range actions.backups {
+			backupAction := &actions.backups[i]
+			if duration, ok := backupAction.Duration(); ok && backupAction.Status.State == "Complete" {
+				result = append(result, timedAction{"backupaction", namespace, backupAction.Labels[policyNameLabel], backupAction.Name, backupAction.Status.EndTime, duration})
+			}
+		}
+		for i := range actions.exports {
+			exportAction := &actions.exports[i]
+			if duration, ok := exportAction.Duration(); ok && exportAction.Status.State == "Complete" {
+				result = append(result, timedAction{"exportaction", namespace, exportAction.Labels[policyNameLabel], exportAction.Name, exportAction.Status.EndTime, duration})
+			}
+		}
+	}
+	return result
+}
+
+// percentile returns the nearest rank percentile of the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func mean(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, duration := range durations {
+		total += duration
+	}
+	return total / time.Duration(len(durations))
+}
+
+// sortedGroups returns the groups sorted by name and kind, their durations
+// sorted for the percentiles.
+func sortedGroups(groups map[string]*durationGroup) []*durationGroup {
+	var sorted []*durationGroup
+	for _, group := range groups {
+		sort.Slice(group.durations, func(i, j int) bool { return group.durations[i] < group.durations[j] })
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].name != sorted[j].name {
+			return sorted[i].name < sorted[j].name
+		}
+		return sorted[i].kind < sorted[j].kind
+	})
+	return sorted
+}
+
+// durationsAudit gives the duration percentiles of the backups and exports
+// per namespace and per policy, and detects the durations growing week over
+// week and the actions taking most of the interval of their policy.
+func durationsAudit(ctx context.Context, r *report.Report, dynamicClient dynamic.Interface, kastenNamespace string, index *actionIndex) error {
+	r.Section("Backup durations")
+
+	actions := timedActions(index)
+	if len(actions) == 0 {
+		r.Println("  No complete backupaction or exportaction to measure")
+		return nil
+	}
+	namespaces := map[string]*durationGroup{}
+	policies := map[string]*durationGroup{}
+	for _, a := range actions {
+		key := a.namespace + "/" + a.kind
+		if namespaces[key] == nil {
+			namespaces[key] = &durationGroup{name: a.namespace, kind: a.kind}
+		}
+		namespaces[key].add(a)
+		if a.policy == "" {
+			continue
+		}
+		key = a.policy + "/" + a.kind
+		if policies[key] == nil {
+			policies[key] = &durationGroup{name: a.policy, kind: a.kind}
+		}
+		policies[key].add(a)
+	}
+
+	growth := getEnvInt("KASTEN_DURATION_GROWTH", defaultDurationGrowth)
+	padding := "  %-30s %-14s %-8s %-10s %-10s %-10s \n"
+	r.Printf(padding, "NAMESPACE", "ACTION", "COUNT", "P50", "P90", "P99")
+	var trends []*durationGroup
+	for _, group := range sortedGroups(namespaces) {
+		r.Printf(padding, group.name, group.kind, fmt.Sprint(len(group.durations)), formatDuration(percentile(group.durations, 50)),
+			formatDuration(percentile(group.durations, 90)), formatDuration(percentile(group.durations, 99)))
+		if len(group.thisWeek) > 0 && len(group.lastWeek) > 0 && mean(group.thisWeek) > mean(group.lastWeek)*time.Duration(100+growth)/100 {
+			trends = append(trends, group)
+		}
+	}
+	for _, group := range trends {
+		r.WarningFor(group.kind+"/"+group.name, "the %ss of namespace %s took %s on average this week against %s the week before", group.kind, group.name,
+			formatDuration(mean(group.thisWeek)), formatDuration(mean(group.lastWeek)))
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+
+	intervals := map[string]time.Duration{}
+	policyList := policy.PolicyList{}
+	err := client.List(ctx, dynamicClient, policy.PolicyResource, kastenNamespace, &policyList)
+	if err != nil && !errors.IsForbidden(err) {
+		return err
+	}
+	for i := range policyList.Items {
+		if interval, ok := policyList.Items[i].Interval(); ok {
+			intervals[policyList.Items[i].Name] = interval
+		}
+	}
+
+	ratio := getEnvInt("KASTEN_INTERVAL_RATIO", defaultIntervalRatio)
+	r.Println("")
+	padding = "  %-30s %-14s %-8s %-10s %-10s %-10s \n"
+	r.Printf(padding, "POLICY", "ACTION", "COUNT", "P50", "P90", "INTERVAL")
+	var slow []*durationGroup
+	for _, group := range sortedGroups(policies) {
+		interval, ok := intervals[group.name]
+		shown := "-"
+		if ok {
+			shown = formatDuration(interval)
+			if group.max.duration > interval*time.Duration(ratio)/100 {
+				slow = append(slow, group)
+			}
+		}
+		r.Printf(padding, group.name, group.kind, fmt.Sprint(len(group.durations)), formatDuration(percentile(group.durations, 50)),
+			formatDuration(percentile(group.durations, 90)), shown)
+	}
+	for _, group := range slow {
+		r.WarningFor(group.kind+"/"+group.name, "%s %s of policy %s took %s of its %s interval, the next run may start before it ends", group.kind, group.max.name, group.name,
+			formatDuration(group.max.duration), formatDuration(intervals[group.name]))
+	}
+	return nil
+}
+
+// formatDuration rounds the duration to what matters for a backup.
+func formatDuration(duration time.Duration) string {
+	if duration >= time.Hour {
+		return duration.Round(time.Minute).String()
+	}
+	return duration.Round(time.Second).String()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaelcourcy/audit-tool/pkg/action"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+)
+
+// failureCause is a kind of failure recognized in the error chain of an
+// action, the first matching cause wins.
+type failureCause struct {
+	name     string
+	keywords []string
+	// describe says why the namespaces fail, detail is the profile,
+	// blueprint... found in the error, it can be empty
+	describe func(detail string) string
+	// field is the error field holding the detail
+	field string
+}
+
+var failureCauses = []failureCause{
+	{
+		name:     "quota exceeded",
+		keywords: []string{"exceeded quota", "quota exceeded", "resourcequota"},
+		describe: func(detail string) string { return "a resource quota is exceeded" },
+	},
+	{
+		name:     "snapshot timeout",
+		keywords: []string{"snapshot"},
+		describe: func(detail string) string { return "the volume snapshots time out" },
+	},
+	{
+		name:     "kanister action failed",
+		keywords: []string{"kanister", "blueprint", "actionset"},
+		field:    "blueprint",
+		describe: func(detail string) string {
+			if detail == "" {
+				return "a Kanister action failed"
+			}
+			return fmt.Sprintf("the Kanister action of blueprint %s failed", detail)
+		},
+	},
+	{
+		// the profile is named in most export errors, only the phrases
+		// telling it can't be reached are matched
+		name: "profile unreachable",
+		keywords: []string{"connect to profile", "reach profile", "access profile", "profile validation", "invalid profile",
+			"objectstore", "object store", "bucket", "no such host", "connection refused", "accessdenied", "access denied"},
+		field: "profile",
+		describe: func(detail string) string {
+			if detail == "" {
+				return "the location profile cannot be reached"
+			}
+			return fmt.Sprintf("profile %s cannot be reached", detail)
+		},
+	},
+}
+
+// profileName finds the profile in an error message when it is not a field.
+var profileName = regexp.MustCompile(`profile[ :"']+([a-z0-9][a-z0-9.-]*)`)
+
+// failure is the last finished action of one kind in a namespace when it
+// failed.
+type failure struct {
+	namespace string
+	kind      string
+	name      string
+	err       action.Error
+}
+
+// failureGroup is the namespaces failing for the same cause.
+type failureGroup struct {
+	cause       string
+	description string
+	namespaces  map[string]bool
+	actions     []string
+}
+
+// classifyFailure returns the cause of the failure and what the report says
+// about it, the root message when no cause is recognized.
+func classifyFailure(err action.Error) (string, string) {
+	chain := err.Chain()
+	var messages []string
+	for _, e := range chain {
+		messages = append(messages, strings.ToLower(e.Message))
+	}
+	text := strings.Join(messages, " ")
+	timeout := strings.Contains(text, "timeout") || strings.Contains(text, "timed out") || strings.Contains(text, "deadline exceeded")
+	for _, cause := range failureCauses {
+		if cause.name == "snapshot timeout" && !timeout {
+			continue
+		}
+		for _, keyword := range cause.keywords {
+			if !strings.Contains(text, keyword) {
+				continue
+			}
+			detail := ""
+			if cause.field != "" {
+				detail, _ = err.Field(cause.field)
+			}
+			if detail == "" && cause.name == "profile unreachable" {
+				if match := profileName.FindStringSubmatch(text); match != nil {
+					detail = match[1]
+				}
+			}
+			return cause.name + "/" + detail, cause.describe(detail)
+		}
+	}
+	if len(chain) == 0 {
+		return "unknown", "the action failed without recording an error"
+	}
+	root := chain[len(chain)-1].Message
+	return "other/" + root, root
+}
+
+// lastFailures returns, for every namespace and kind of action, the last
+// finished action when it failed. An older failure followed by a success is
+// not a current problem.
+func lastFailures(index *actionIndex) []failure {
+	var failures []failure
+	for namespace, actions := range index.namespaces {
+		last := map[string]failure{}
+		lastEnd := map[string]time.Time{}
+		record := func(kind string, name string, state string, end time.Time, err action.Error, exceptions []action.Error) {
+			if (state != "Complete" && state != "Failed") || end.Before(lastEnd[kind]) {
+				return
+			}
+			lastEnd[kind] = end
+			if state == "Complete" {
+				delete(last, kind)
+				return
+			}
+			if len(err.Chain()) == 0 && len(exceptions) > 0 {
+				err = exceptions[0]
+			}
+			last[kind] = failure{namespace: namespace, kind: kind, name: name, err: err}
+		}
+		for _, a := range actions.backups {
+			record("backupaction", a.Name, a.Status.State, a.Status.EndTime, a.Status.Error, a.Status.Exceptions)
+		}
+		for _, a := range actions.exports {
+			record("exportaction", a.Name, a.Status.State, a.Status.EndTime, a.Status.Error, a.Status.Exceptions)
+		}
+		for _, a := range actions.restores {
+			record("restoreaction", a.Name, a.Status.State, a.Status.EndTime, a.Status.Error, a.Status.Exceptions)
+		}
+		for _, f := range last {
+			failures = append(failures, f)
+		}
+	}
+	return failures
+}
+
+// failuresAudit groups the namespaces whose last action failed by the cause
+// found in the error Kasten recorded, one finding per cause instead of one
+// per namespace.
+func failuresAudit(r *report.Report, index *actionIndex) {
+	r.Section("Failure causes")
+
+	groups := map[string]*failureGroup{}
+	for _, f := range lastFailures(index) {
+		key, description := classifyFailure(f.err)
+		group, ok := groups[key]
+		if !ok {
+			group = &failureGroup{cause: key, description: description, namespaces: map[string]bool{}}
+			groups[key] = group
+		}
+		group.namespaces[f.namespace] = true
+		group.actions = append(group.actions, f.kind+"/"+f.namespace+"/"+f.name)
+	}
+	if len(groups) == 0 {
+		r.Println("  --> The last action of every namespace succeeded")
+		return
+	}
+
+	var sorted []*failureGroup
+	for _, group := range groups {
+		sort.Strings(group.actions)
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].namespaces) != len(sorted[j].namespaces) {
+			return len(sorted[i].namespaces) > len(sorted[j].namespaces)
+		}
+		return sorted[i].cause < sorted[j].cause
+	})
+	padding := "  %-12s %-60s %-60s \n"
+	r.Printf(padding, "NAMESPACES", "CAUSE", "EXAMPLE")
+	for _, group := range sorted {
+		r.Printf(padding, fmt.Sprint(len(group.namespaces)), group.description, group.actions[0])
+	}
+	for _, group := range sorted {
+		r.WarningFor(group.cause, "%d namespaces failing because %s", len(group.namespaces), group.description)
+	}
+}
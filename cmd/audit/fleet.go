@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+)
+
+// clusterAudit is the audit of one context, its report is buffered until
+// every cluster is done so the outputs do not interleave.
+type clusterAudit struct {
+	context string
+	out     bytes.Buffer
+	r       *report.Report
+	err     error
+}
+
+// fleetAudit audits the contexts in parallel, prints the report of each
+// cluster in the order of the contexts, then the fleet summary.
+func fleetAudit(ctx context.Context, out io.Writer, kubeconfig string, contexts []string, kastenNamespace string, kastenRelease string) {
+	audits := make([]*clusterAudit, len(contexts))
+	var wg sync.WaitGroup
+	for i, kubeContext := range contexts {
+		audit := &clusterAudit{context: kubeContext}
+		audit.r = report.New(&audit.out)
+		audits[i] = audit
+
+		a, err := contextAuditor(kubeconfig, kubeContext, audit.r, kastenNamespace, kastenRelease)
+		if err != nil {
+			audit.err = err
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			audit.err = a.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for _, audit := range audits {
+		title := "Cluster " + audit.context
+		line := strings.Repeat("#", len(title))
+		fmt.Fprintf(out, "\n%s\n%s\n%s\n", line, title, line)
+		out.Write(audit.out.Bytes())
+		if audit.err != nil {
+			fmt.Fprintf(out, "  --> the audit of %s failed: %s \n", audit.context, audit.err)
+		}
+	}
+	fleetSummary(report.New(out), audits)
+}
+
+// contextAuditor returns the auditor of a context of the kubeconfig.
+var contextAuditor = func(kubeconfig string, kubeContext string, r *report.Report, kastenNamespace string, kastenRelease string) (*auditor, error) {
+	config, err := client.ContextConfig(kubeconfig, kubeContext)
+	if err != nil {
+		return nil, err
+	}
+	return newAuditor(config, r, kastenNamespace, kastenRelease)
+}
+
+func fleetSummary(r *report.Report, audits []*clusterAudit) {
+	r.Section("Fleet summary")
+	padding := "  %-30s %-12s %-12s %-12s %-10s %-10s %-10s \n"
+	r.Printf(padding, "CLUSTER", "KASTEN", "UNPROTECTED", "WORST RPO", "IMMUTABLE", "CRITICAL", "WARNING")
+	for _, audit := range audits {
+		if audit.err != nil {
+			r.Printf(padding, audit.context, "-", "-", "-", "-", "-", "failed")
+			continue
+		}
+		summary := audit.r.Summary
+		version := summary.KastenVersion
+		if version == "" {
+			version = "-"
+		}
+		r.Printf(padding, audit.context, version, fmt.Sprint(summary.UnprotectedNamespaces), formatRPO(summary.WorstRPO), yesNo(summary.Immutable),
+			fmt.Sprint(audit.r.Count(report.Critical)), fmt.Sprint(audit.r.Count(report.Warning)))
+	}
+}
+
+// formatRPO gives the duration in days and hours like the RPO of the
+// namespaces.
+func formatRPO(rpo time.Duration) string {
+	if rpo == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%dd%dh", int64(rpo.Hours()/24), int64(rpo.Hours())%24)
+}
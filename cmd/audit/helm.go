@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// defaultKeepMaxActions is the default of the Kasten chart, above it the
+	// list calls on the actions start to be slow.
+	defaultKeepMaxActions = 1000
+	// maxDaemonPeriod is the longest period between two garbage collections we
+	// consider acceptable, in seconds.
+	maxDaemonPeriod = 24 * 60 * 60
+)
+
+var garbageCollectorPermissions = permission.Join(kastenInstallPermissions, actionsPermissions)
+
+// helmValues returns the values the release is running with, the chart
+// defaults overridden by the values given at install or upgrade.
+func helmValues(release *release.Release) (chartutil.Values, error) {
+	return chartutil.CoalesceValues(release.Chart, release.Config)
+}
+
+// helmValue returns the value at the dotted path, nil if it is not set.
+func helmValue(values chartutil.Values, path string) interface{} {
+	value, err := values.PathValue(path)
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+func helmInt(values chartutil.Values, path string) (int64, bool) {
+	switch v := helmValue(values, path).(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func helmBool(values chartutil.Values, path string) (bool, bool) {
+	switch v := helmValue(values, path).(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// flattenValues returns the leaves of a values table keyed by their dotted
+// path.
+func flattenValues(prefix string, table map[string]interface{}, leaves map[string]interface{}) {
+	for key, value := range table {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if child, ok := value.(map[string]interface{}); ok {
+			flattenValues(path, child, leaves)
+		} else {
+			leaves[path] = value
+		}
+	}
+}
+
+func garbageCollectorAudit(r *report.Report, release *release.Release, actions *actionIndex) error {
+	r.Section("Garbage collector")
+
+	values, err := helmValues(release)
+	if err != nil {
+		return err
+	}
+	gc, err := values.Table("garbagecollector")
+	if err != nil {
+		r.Warning("no garbagecollector settings found in the values of the release %s", release.Name)
+		return nil
+	}
+	leaves := map[string]interface{}{}
+	flattenValues("garbagecollector", gc, leaves)
+	var paths []string
+	for path := range leaves {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	padding := "  %-50s %-20s \n"
+	r.Printf(padding, "SETTING", "VALUE")
+	for _, path := range paths {
+		r.Printf(padding, path, fmt.Sprint(leaves[path]))
+	}
+
+	if daemonPeriod, ok := helmInt(values, "garbagecollector.daemonPeriod"); ok && daemonPeriod > maxDaemonPeriod {
+		r.Warning("the garbage collector only runs every %d seconds (garbagecollector.daemonPeriod)", daemonPeriod)
+	}
+	actionsEnabled, ok := helmBool(values, "garbagecollector.actions.enabled")
+	if ok && !actionsEnabled {
+		r.Warning("the action collectors are disabled (garbagecollector.actions.enabled=false), action CRs accumulate")
+	}
+	keepMaxActions, ok := helmInt(values, "garbagecollector.keepMaxActions")
+	switch {
+	case !ok:
+		keepMaxActions = defaultKeepMaxActions
+	case keepMaxActions <= 0:
+		r.Warning("garbagecollector.keepMaxActions is %d, the number of actions kept per policy is not limited", keepMaxActions)
+	case keepMaxActions > defaultKeepMaxActions:
+		r.Warning("the garbage collector keeps %d actions per policy (garbagecollector.keepMaxActions), above %d the list calls on actions slow down", keepMaxActions, defaultKeepMaxActions)
+	}
+
+	// what is really kept on the cluster, keepMaxActions applies to the
+	// actions of each policy and action type
+	total := 0
+	perPolicy := map[string]int64{}
+	for _, namespaceActions := range actions.namespaces {
+		total += len(namespaceActions.backups)
+		for _, backupAction := range namespaceActions.backups {
+			perPolicy[backupAction.Labels[policyNameLabel]]++
+		}
+	}
+	r.Printf("  There are %d backupactions on the cluster \n", total)
+	var policies []string
+	for policyName := range perPolicy {
+		policies = append(policies, policyName)
+	}
+	sort.Strings(policies)
+	for _, policyName := range policies {
+		// the manual backups have no policy label
+		if policyName == "" || keepMaxActions <= 0 || perPolicy[policyName] <= keepMaxActions {
+			continue
+		}
+		r.WarningFor(policyName, "%d backupactions of policy %s are kept while the garbage collector should keep %d, actions are accumulating", perPolicy[policyName], policyName, keepMaxActions)
+	}
+	return nil
+}
+
+// helmRule evaluates one aspect of the release values, observe returns what
+// is shown in the report and check raises the findings.
+type helmRule struct {
+	name    string
+	observe func(values chartutil.Values) string
+	check   func(r *report.Report, values chartutil.Values, defaults chartutil.Values)
+}
+
+// limiters are compared with the chart defaults, a limit far from the default
+// either blocks the backups or overloads the cluster.
+var limiters = []string{
+	"limiter.concurrentSnapshotConversions",
+	"limiter.genericVolumeSnapshots",
+	"limiter.genericVolumeCopies",
+	"limiter.genericVolumeRestores",
+	"limiter.csiSnapshots",
+	"limiter.providerSnapshots",
+	"limiter.imageCopies",
+	"limiter.executorReplicas",
+	"limiter.executorThreads",
+}
+
+// minCatalogSize is the catalog size recommended by Kasten.
+var minCatalogSize = resource.MustParse("20Gi")
+
+var helmRules = []helmRule{
+	{
+		name:    "authentication",
+		observe: authMethod,
+		check: func(r *report.Report, values chartutil.Values, _ chartutil.Values) {
+			switch authMethod(values) {
+			case "none":
+				// critical only when the dashboard is exposed, which the
+				// dashboard exposure check raises
+				r.Warning("the dashboard has no authentication (auth.*.enabled are all false)")
+			case "basic":
+				r.Warning("the dashboard uses basic authentication (auth.basicAuth.enabled=true), prefer OIDC, LDAP or OpenShift authentication")
+			}
+		},
+	},
+	{
+		name: "dashboard exposure",
+		observe: func(values chartutil.Values) string {
+			exposures := dashboardExposures(values)
+			if len(exposures) == 0 {
+				return "none"
+			}
+			return strings.Join(exposures, ",")
+		},
+		check: func(r *report.Report, values chartutil.Values, _ chartutil.Values) {
+			if ingress, _ := helmBool(values, "ingress.create"); ingress {
+				if tls, _ := helmBool(values, "ingress.tls.enabled"); !tls {
+					r.Warning("the dashboard ingress has no TLS (ingress.tls.enabled=%v)", helmValue(values, "ingress.tls.enabled"))
+				}
+			}
+			if route, _ := helmBool(values, "route.enabled"); route {
+				if tls, _ := helmBool(values, "route.tls.enabled"); !tls {
+					r.Warning("the dashboard route has no TLS (route.tls.enabled=%v)", helmValue(values, "route.tls.enabled"))
+				}
+			}
+		},
+	},
+	{
+		name: "primary key",
+		observe: func(values chartutil.Values) string {
+			if path, key := primaryKey(values); path != "" {
+				return path + "=" + key
+			}
+			return "not set"
+		},
+		check: func(r *report.Report, values chartutil.Values, _ chartutil.Values) {
+			if path, _ := primaryKey(values); path == "" {
+				r.Warning("the primary key is not managed by a KMS (encryption.primaryKey is not set)")
+			}
+		},
+	},
+	{
+		name: "monitoring",
+		observe: func(values chartutil.Values) string {
+			prometheus, _ := helmBool(values, "prometheus.server.enabled")
+			grafana, _ := helmBool(values, "grafana.enabled")
+			return fmt.Sprintf("prometheus=%v grafana=%v", prometheus, grafana)
+		},
+		check: func(r *report.Report, values chartutil.Values, _ chartutil.Values) {
+			if prometheus, _ := helmBool(values, "prometheus.server.enabled"); !prometheus {
+				r.Warning("prometheus is disabled (prometheus.server.enabled=false), failed policies can't be alerted on")
+			}
+		},
+	},
+	{
+		name: "persistence",
+		observe: func(values chartutil.Values) string {
+			if enabled, _ := helmBool(values, "global.persistence.enabled"); !enabled {
+				return "disabled"
+			}
+			return fmt.Sprintf("catalog=%s storageClass=%v", catalogSize(values), helmValue(values, "global.persistence.storageClass"))
+		},
+		check: func(r *report.Report, values chartutil.Values, _ chartutil.Values) {
+			if enabled, _ := helmBool(values, "global.persistence.enabled"); !enabled {
+				r.Critical("persistence is disabled (global.persistence.enabled=false), the catalog is lost when its pod restarts")
+				return
+			}
+			size := catalogSize(values)
+			quantity, err := resource.ParseQuantity(size)
+			if err == nil && quantity.Cmp(minCatalogSize) < 0 {
+				r.Warning("the catalog volume is %s, below the recommended %s (global.persistence.catalog.size)", size, minCatalogSize.String())
+			}
+		},
+	},
+	{
+		name: "kanister sidecar injection",
+		observe: func(values chartutil.Values) string {
+			if enabled, _ := helmBool(values, "injectKanisterSidecar.enabled"); !enabled {
+				return "disabled"
+			}
+			return fmt.Sprintf("enabled namespaceSelector=%v", helmValue(values, "injectKanisterSidecar.namespaceSelector.matchLabels"))
+		},
+		check: func(r *report.Report, values chartutil.Values, _ chartutil.Values) {
+			if enabled, _ := helmBool(values, "injectKanisterSidecar.enabled"); !enabled {
+				return
+			}
+			selector, _ := helmValue(values, "injectKanisterSidecar.namespaceSelector.matchLabels").(map[string]interface{})
+			if len(selector) == 0 {
+				r.Warning("the kanister sidecar is injected in the workloads of every namespace (injectKanisterSidecar.namespaceSelector is empty)")
+			}
+		},
+	},
+	{
+		name: "limiters",
+		observe: func(values chartutil.Values) string {
+			var observed []string
+			for _, path := range limiters {
+				if value, ok := helmInt(values, path); ok {
+					observed = append(observed, fmt.Sprintf("%s=%d", strings.TrimPrefix(path, "limiter."), value))
+				}
+			}
+			return strings.Join(observed, " ")
+		},
+		check: func(r *report.Report, values chartutil.Values, defaults chartutil.Values) {
+			for _, path := range limiters {
+				value, ok := helmInt(values, path)
+				if !ok {
+					continue
+				}
+				if value < 1 {
+					r.WarningFor(path, "%s=%d blocks the operations it limits", path, value)
+				} else if def, ok := helmInt(defaults, path); ok && (value > 4*def || 4*value < def) {
+					r.WarningFor(path, "%s=%d is far from the chart default %d", path, value, def)
+				}
+			}
+		},
+	},
+	{
+		name: "fips",
+		observe: func(values chartutil.Values) string {
+			enabled, _ := helmBool(values, "fips.enabled")
+			return fmt.Sprint(enabled)
+		},
+	},
+	{
+		name: "custom CA",
+		observe: func(values chartutil.Values) string {
+			if name, _ := helmValue(values, "cacertconfigmap.name").(string); name != "" {
+				return "configmap " + name
+			}
+			return "none"
+		},
+	},
+}
+
+// primaryKey returns the value which sets the KMS key protecting the primary
+// key, empty if the primary key is derived from a passphrase.
+func primaryKey(values chartutil.Values) (string, string) {
+	for _, path := range []string{"encryption.primaryKey.awsCmkKeyId", "encryption.primaryKey.vaultTransitKeyName"} {
+		if key, _ := helmValue(values, path).(string); key != "" {
+			return path, key
+		}
+	}
+	return "", ""
+}
+
+func catalogSize(values chartutil.Values) string {
+	if size, _ := helmValue(values, "global.persistence.catalog.size").(string); size != "" {
+		return size
+	}
+	size, _ := helmValue(values, "global.persistence.size").(string)
+	return size
+}
+
+// authMethod returns the authentication protecting the dashboard.
+func authMethod(values chartutil.Values) string {
+	methods := []struct {
+		path   string
+		method string
+	}{
+		{"auth.oidcAuth.enabled", "oidc"},
+		{"auth.openshift.enabled", "openshift"},
+		{"auth.ldap.enabled", "ldap"},
+		{"auth.tokenAuth.enabled", "token"},
+		{"auth.basicAuth.enabled", "basic"},
+	}
+	for _, m := range methods {
+		if enabled, _ := helmBool(values, m.path); enabled {
+			return m.method
+		}
+	}
+	return "none"
+}
+
+// dashboardExposures returns how the chart exposes the gateway outside of the
+// cluster.
+func dashboardExposures(values chartutil.Values) []string {
+	var exposures []string
+	if create, _ := helmBool(values, "externalGateway.create"); create {
+		exposures = append(exposures, "loadbalancer")
+	}
+	if create, _ := helmBool(values, "ingress.create"); create {
+		exposures = append(exposures, "ingress")
+	}
+	if enabled, _ := helmBool(values, "route.enabled"); enabled {
+		exposures = append(exposures, "route")
+	}
+	return exposures
+}
+
+func helmValuesAudit(r *report.Report, release *release.Release) error {
+	r.Section("Helm values")
+
+	values, err := helmValues(release)
+	if err != nil {
+		return err
+	}
+	defaults := chartutil.Values(release.Chart.Values)
+	padding := "  %-30s %-60s \n"
+	r.Printf(padding, "RULE", "OBSERVED")
+	for _, rule := range helmRules {
+		r.Printf(padding, rule.name, rule.observe(values))
+	}
+	for _, rule := range helmRules {
+		if rule.check != nil {
+			rule.check(r, values, defaults)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/history"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultHistoryDir keeps the runs when the audit does not run in a pod.
+	defaultHistoryDir = "audit-history"
+	// defaultHistoryKeep is the number of runs kept, it can be changed with
+	// KASTEN_HISTORY_KEEP.
+	defaultHistoryKeep = 30
+)
+
+// historyPermissions let the audit keep its runs in configmaps of the kasten
+// namespace, the rbac command only grants them when KASTEN_HISTORY is
+// configmap.
+var historyPermissions = []permission.Permission{
+	{Resource: "configmaps", Verb: "list", KastenNamespace: true, Optional: true},
+	{Resource: "configmaps", Verb: "create", KastenNamespace: true, Optional: true},
+	{Resource: "configmaps", Verb: "delete", KastenNamespace: true, Optional: true},
+}
+
+// historyStore returns where the runs are kept from KASTEN_HISTORY: "none",
+// "configmap" or a directory. By default the runs go to the audit-history
+// directory outside of a pod and are not kept in a pod, the job stays read
+// only unless configmap or a mounted directory is asked. The client is
+// created when the configmaps need one and none is given.
+func historyStore(kastenNamespace string, corev1Client kubernetes.Interface) (history.Store, error) {
+	location := os.Getenv("KASTEN_HISTORY")
+	if location == "" {
+		location = defaultHistoryDir
+		if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+			location = "none"
+		}
+	}
+	switch location {
+	case "none":
+		return nil, nil
+	case "configmap":
+		if corev1Client == nil {
+			config, err := client.Config()
+			if err != nil {
+				return nil, err
+			}
+			corev1Client, err = kubernetes.NewForConfig(config)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return history.ConfigMapStore{Client: corev1Client, Namespace: kastenNamespace}, nil
+	}
+	return history.DirStore{Path: location}, nil
+}
+
+// saveRun keeps the result of the audit and drops the runs above
+// KASTEN_HISTORY_KEEP.
+func saveRun(ctx context.Context, store history.Store, r *report.Report) error {
+	run := history.Run{Time: now(), Findings: r.Findings, Summary: r.Summary}
+	name, err := store.Save(ctx, run)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nThe result of the audit is kept as run %s \n", name)
+	return history.Prune(ctx, store, getEnvInt("KASTEN_HISTORY_KEEP", defaultHistoryKeep))
+}
+
+// diffRuns prints the findings new, resolved and persisting between two
+// runs and the namespaces whose RPO changed, the last two runs when no run is
+// named.
+func diffRuns(ctx context.Context, r *report.Report, store history.Store, names []string) error {
+	if len(names) == 0 {
+		all, err := store.List(ctx)
+		if err != nil {
+			return err
+		}
+		if len(all) < 2 {
+			return fmt.Errorf("%d runs in the history, diff needs two", len(all))
+		}
+		names = all[len(all)-2:]
+	}
+	if len(names) != 2 {
+		return fmt.Errorf("diff needs the names of two runs, got %d", len(names))
+	}
+	from, err := store.Load(ctx, names[0])
+	if err != nil {
+		return err
+	}
+	to, err := store.Load(ctx, names[1])
+	if err != nil {
+		return err
+	}
+	diff := history.Compare(from, to)
+
+	r.Section(fmt.Sprintf("Changes from run %s to run %s", names[0], names[1]))
+	r.Printf("  %d new, %d resolved and %d persisting findings \n", len(diff.New), len(diff.Resolved), len(diff.Persisting))
+	for _, part := range []struct {
+		title    string
+		findings []report.Finding
+	}{
+		{"New findings", diff.New},
+		{"Resolved findings", diff.Resolved},
+		{"Persisting findings", diff.Persisting},
+	} {
+		r.Section(part.title)
+		if len(part.findings) == 0 {
+			r.Println("  None")
+		}
+		for _, finding := range part.findings {
+			r.Printf("  %-8s %-35s %s \n", finding.Severity, finding.Section, finding.Message)
+		}
+	}
+
+	r.Section("RPO changes")
+	if len(diff.RPO) == 0 {
+		r.Println("  No RPO changed")
+		return nil
+	}
+	padding := "  %-40s %-12s %-12s \n"
+	r.Printf(padding, "NAMESPACE", "BEFORE", "AFTER")
+	for _, change := range diff.RPO {
+		r.Printf(padding, change.Namespace, historyRPO(change.Before), historyRPO(change.After))
+	}
+	return nil
+}
+
+// historyRPO shows the RPO of a namespace in a run, "none" without a
+// successful backup and "-" when it was not audited.
+func historyRPO(rpo history.NamespaceRPO) string {
+	if !rpo.Audited {
+		return "-"
+	}
+	if !rpo.Protected {
+		return "none"
+	}
+	return formatRPO(rpo.RPO)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"helm.sh/helm/v3/pkg/chartutil"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultRestartThreshold is the number of restarts above which a Kasten
+// container is reported, it can be changed with KASTEN_RESTART_THRESHOLD.
+const defaultRestartThreshold = 5
+
+// kastenDeployments are the deployments created by the Kasten chart. since is
+// the first version shipping the deployment and value, when set, the helm
+// value which must be true for the chart to create it.
+var kastenDeployments = []struct {
+	name  string
+	since string
+	value string
+}{
+	{"aggregatedapis-svc", "", ""},
+	{"auth-svc", "", ""},
+	{"catalog-svc", "", ""},
+	{"controllermanager-svc", "4.5.0", ""},
+	{"crypto-svc", "", ""},
+	{"dashboardbff-svc", "5.0.0", ""},
+	{"executor-svc", "", ""},
+	{"frontend-svc", "", ""},
+	{"gateway", "", ""},
+	{"jobs-svc", "", ""},
+	{"kanister-svc", "", ""},
+	{"logging-svc", "", ""},
+	{"metering-svc", "", ""},
+	{"state-svc", "", ""},
+	{"prometheus-server", "", "prometheus.server.enabled"},
+	{"grafana", "", "grafana.enabled"},
+}
+
+// expectedDeployments returns the deployments a healthy install of this
+// version with these values must have.
+func expectedDeployments(appVersion string, values chartutil.Values) []string {
+	version, err := semver.NewVersion(appVersion)
+	var expected []string
+	for _, deployment := range kastenDeployments {
+		if deployment.since != "" && err == nil && version.LessThan(semver.MustParse(deployment.since)) {
+			continue
+		}
+		if deployment.value != "" {
+			if enabled, ok := helmBool(values, deployment.value); ok && !enabled {
+				continue
+			}
+		}
+		expected = append(expected, deployment.name)
+	}
+	return expected
+}
+
+// listPods reads the pods of the namespace in pages.
+func listPods(ctx context.Context, corev1Client kubernetes.Interface, namespace string) ([]v1.Pod, error) {
+	var pods []v1.Pod
+	_, err := client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.CoreV1().Pods(namespace).List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		pods = append(pods, *object.(*v1.Pod))
+		return nil
+	})
+	return pods, err
+}
+
+// podProblems returns what is wrong with the containers of the pod, a
+// completed pod has no problem.
+func podProblems(pod v1.Pod, restartThreshold int) []string {
+	var problems []string
+	if pod.Status.Phase == v1.PodSucceeded {
+		return nil
+	}
+	if pod.Status.Phase == v1.PodPending {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == v1.PodScheduled && condition.Status != v1.ConditionTrue {
+				problems = append(problems, fmt.Sprintf("is pending and can't be scheduled: %s %s", condition.Reason, condition.Message))
+			}
+		}
+	}
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, c := range statuses {
+		waiting := c.State.Waiting
+		if waiting != nil {
+			switch waiting.Reason {
+			case "CrashLoopBackOff", "ImagePullBackOff", "ErrImagePull", "CreateContainerConfigError", "InvalidImageName":
+				problems = append(problems, fmt.Sprintf("container %s is in %s: %s", c.Name, waiting.Reason, waiting.Message))
+			}
+		}
+		if terminated := c.LastTerminationState.Terminated; terminated != nil && terminated.Reason == "OOMKilled" {
+			problems = append(problems, fmt.Sprintf("container %s was OOMKilled at %s, raise its memory limit", c.Name, terminated.FinishedAt))
+		}
+		if int(c.RestartCount) > restartThreshold {
+			problems = append(problems, fmt.Sprintf("container %s restarted %d times", c.Name, c.RestartCount))
+		}
+	}
+	if pod.Status.Phase == v1.PodRunning {
+		for _, c := range pod.Status.ContainerStatuses {
+			if !c.Ready && c.State.Waiting == nil {
+				problems = append(problems, fmt.Sprintf("container %s is not ready", c.Name))
+			}
+		}
+	}
+	return problems
+}
+
+// kastenWorkloadsAudit reports the unhealthy pods of the Kasten namespace,
+// the deployments below their desired replicas and the missing ones.
+func kastenWorkloadsAudit(r *report.Report, pods []v1.Pod, deployments []appsv1.Deployment, expected []string, kastenNamespace string, restartThreshold int) {
+	podsInError := false
+	for _, pod := range pods {
+		problems := podProblems(pod, restartThreshold)
+		for _, problem := range problems {
+			r.Printf("  pod %s %s \n", pod.Name, problem)
+		}
+		podsInError = podsInError || len(problems) > 0
+	}
+	if podsInError {
+		r.Warning("some pods in the kasten namespace %s are on error", kastenNamespace)
+	} else {
+		r.Printf("  --> No pods in the kasten namespace %s are on error \n", kastenNamespace)
+	}
+
+	padding := "  %-30s %-10s %-10s \n"
+	r.Printf(padding, "DEPLOYMENT", "DESIRED", "AVAILABLE")
+	found := map[string]bool{}
+	sort.Slice(deployments, func(i, j int) bool { return deployments[i].Name < deployments[j].Name })
+	for _, deployment := range deployments {
+		found[deployment.Name] = true
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		r.Printf(padding, deployment.Name, fmt.Sprint(desired), fmt.Sprint(deployment.Status.AvailableReplicas))
+		if deployment.Status.AvailableReplicas < desired {
+			r.WarningFor(deployment.Name, "deployment %s has %d available replicas out of %d", deployment.Name, deployment.Status.AvailableReplicas, desired)
+		}
+	}
+	var missing []string
+	for _, name := range expected {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		r.Critical("the kasten deployments %s are missing", strings.Join(missing, ","))
+	} else {
+		r.Println("  --> All the expected kasten deployments are present")
+	}
+}
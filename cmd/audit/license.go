@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+
+	"github.com/michaelcourcy/audit-tool/pkg/license"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultFreeNodes is the number of nodes Kasten protects without a
+	// license, it can be changed with KASTEN_FREE_NODES.
+	defaultFreeNodes = 5
+	// defaultLicenseExpiryDays is how many days before its end a license is
+	// reported, it can be changed with KASTEN_LICENSE_EXPIRY_DAYS.
+	defaultLicenseExpiryDays = 30
+	// nodesNotCounted is shown when the cluster information check, which
+	// counts the nodes, was skipped.
+	nodesNotCounted = "  The nodes were not counted, the cluster information check was skipped, the node limit is not checked"
+)
+
+var licensePermissions = []permission.Permission{
+	{Resource: "secrets", Verb: "get", KastenNamespace: true},
+	{Resource: "configmaps", Verb: "get", KastenNamespace: true},
+}
+
+func licenseAudit(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface, kastenNamespace string, nodes int) error {
+	r.Section("Licensing")
+
+	data, found, err := licenseData(ctx, corev1Client, kastenNamespace)
+	if err != nil {
+		return err
+	}
+	if !found {
+		freeNodes := getEnvInt("KASTEN_FREE_NODES", defaultFreeNodes)
+		r.Printf("  No license found in %s, Kasten runs in the free tier \n", kastenNamespace)
+		if nodes == 0 {
+			r.Println(nodesNotCounted)
+		} else if nodes > freeNodes {
+			r.Critical("the cluster has %d nodes, above the %d nodes of the free tier", nodes, freeNodes)
+		}
+		return nil
+	}
+	if len(data) == 0 {
+		r.Warning("the license %s has no license key, it is malformed", license.SecretName)
+		return nil
+	}
+
+	l, err := license.Decode(data)
+	if err != nil {
+		r.Warning("the license %s can't be decoded: %s", license.SecretName, err)
+		return nil
+	}
+	r.Printf("  License %s for %s \n", l.ID, l.CustomerName)
+
+	expiry, ok, err := l.Expiry()
+	if err != nil {
+		r.Warning("%s", err)
+	} else if ok {
+		left := expiry.Sub(now())
+		days := int(left.Hours() / 24)
+		switch {
+		case left < 0:
+			r.Critical("the license expired on %s", expiry.Format("2006-01-02"))
+		case days < getEnvInt("KASTEN_LICENSE_EXPIRY_DAYS", defaultLicenseExpiryDays):
+			r.Warning("the license expires in %d days on %s", days, expiry.Format("2006-01-02"))
+		default:
+			r.Printf("  The license expires on %s \n", expiry.Format("2006-01-02"))
+		}
+	}
+
+	if l.Restrictions.Nodes == 0 {
+		r.Println("  The license has no node limit")
+	} else if nodes == 0 {
+		r.Println(nodesNotCounted)
+	} else if int64(nodes) > l.Restrictions.Nodes {
+		r.Critical("the cluster has %d nodes, above the %d nodes of the license", nodes, l.Restrictions.Nodes)
+	} else {
+		r.Printf("  The cluster uses %d of the %d nodes of the license \n", nodes, l.Restrictions.Nodes)
+	}
+	return nil
+}
+
+// licenseData returns the license from the secret or the configmap, false
+// when there is none. The data is empty when the secret or the configmap has
+// no license key.
+func licenseData(ctx context.Context, corev1Client kubernetes.Interface, kastenNamespace string) ([]byte, bool, error) {
+	secret, err := corev1Client.CoreV1().Secrets(kastenNamespace).Get(ctx, license.SecretName, metav1.GetOptions{})
+	if err == nil {
+		return secret.Data["license"], true, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, false, err
+	}
+	configMap, err := corev1Client.CoreV1().ConfigMaps(kastenNamespace).Get(ctx, license.SecretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(configMap.Data["license"]), true, nil
+}
@@ -2,21 +2,30 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	helm "github.com/mittwald/go-helm-client"
 	log "github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/release"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 
-	"github.com/michaelcourcy/audit-tool/pkg/action"
 	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
 	"github.com/michaelcourcy/audit-tool/pkg/profile"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -32,125 +41,373 @@ func init() {
 	log.SetLevel(log.WarnLevel)
 }
 
+// now is the time the ages are computed at, the collection time of the
+// snapshot when analyzing one.
+var now = time.Now
+
+// serviceAccountName is the name of the service account, roles and bindings
+// created by the rbac command.
+const serviceAccountName = "audit-tool"
+
+// defaultMaxActions is the number of most recent backupactions shown per
+// namespace, it can be changed with KASTEN_MAX_ACTIONS. It only limits the
+// output, every backupaction is read for the RPO and the statistics.
+const defaultMaxActions = 10
+
+// defaultFailureStreak is the number of backupactions failing in a row since
+// the last successful one which is reported, it can be changed with
+// KASTEN_FAILURE_STREAK.
+const defaultFailureStreak = 3
+
+// auditor holds the clients of the audited cluster and what the checks pass
+// to each other.
+type auditor struct {
+	r                  *report.Report
+	kastenNamespace    string
+	kastenRelease      string
+	corev1Client       kubernetes.Interface
+	discoveryClient    discovery.DiscoveryInterface
+	metadataClient     metadata.Interface
+	dynamicClient      dynamic.Interface
+	helmClient         releaseGetter
+	cluster            clusterFacts
+	release            *release.Release
+	namespacesWithPVCs map[string][]string
+	actions            *actionIndex
+}
+
+// clusterFacts is what clusterInfo learns about the cluster.
+type clusterFacts struct {
+	kubernetesVersion string
+	// openshiftVersion is empty when the cluster is not OpenShift.
+	openshiftVersion string
+	nodes            int
+}
+
+// releaseGetter reads a helm release, helm.Client in the audit.
+type releaseGetter interface {
+	GetRelease(name string) (*release.Release, error)
+}
+
+// check is a step of the audit and the permissions it needs to run.
+type check struct {
+	title       string
+	permissions []permission.Permission
+	run         func(ctx context.Context, a *auditor) error
+}
+
+var checks = []check{
+	{"Information about the cluster", clusterInfoPermissions, func(ctx context.Context, a *auditor) (err error) {
+		a.cluster, err = clusterInfo(ctx, a.r, a.corev1Client, a.discoveryClient, a.dynamicClient)
+		return err
+	}},
+	{"Node capacity for Kasten workers", nodeCapacityPermissions, func(ctx context.Context, a *auditor) error {
+		return nodeCapacityAudit(ctx, a.r, a.corev1Client)
+	}},
+	{"Checking Kasten install", kastenInstallPermissions, func(ctx context.Context, a *auditor) (err error) {
+		a.release, err = checkKastenInstall(ctx, a.r, a.corev1Client, a.kastenNamespace, a.kastenRelease, a.helmClient, a.cluster)
+		return err
+	}},
+	{"Helm values", kastenInstallPermissions, func(ctx context.Context, a *auditor) error {
+		return helmValuesAudit(a.r, a.release)
+	}},
+	{"Licensing", licensePermissions, func(ctx context.Context, a *auditor) error {
+		return licenseAudit(ctx, a.r, a.corev1Client, a.kastenNamespace, a.cluster.nodes)
+	}},
+	{"Kasten storage", kastenStoragePermissions, func(ctx context.Context, a *auditor) error {
+		return kastenStorageAudit(ctx, a.r, a.corev1Client, a.metadataClient, a.kastenNamespace)
+	}},
+	{"Dashboard exposure", dashboardPermissions, func(ctx context.Context, a *auditor) error {
+		return dashboardAudit(ctx, a.r, a.corev1Client, a.dynamicClient, a.discoveryClient, a.release, a.kastenNamespace)
+	}},
+	{"Garbage collector", garbageCollectorPermissions, func(ctx context.Context, a *auditor) error {
+		index, err := a.actionIndex(ctx)
+		if err != nil {
+			return err
+		}
+		return garbageCollectorAudit(a.r, a.release, index)
+	}},
+	{"Auditing profiles", profilesPermissions, func(ctx context.Context, a *auditor) error {
+		return profilesAudit(ctx, a.r, a.dynamicClient, a.kastenNamespace)
+	}},
+	{"Disaster recovery", disasterRecoveryPermissions, func(ctx context.Context, a *auditor) error {
+		return disasterRecoveryAudit(ctx, a.r, a.corev1Client, a.dynamicClient, a.kastenNamespace)
+	}},
+	{"Kasten RBAC", rbacPermissions, func(ctx context.Context, a *auditor) error {
+		return rbacAudit(ctx, a.r, a.corev1Client, a.kastenNamespace)
+	}},
+	{"Multi-cluster manager", multiClusterPermissions, func(ctx context.Context, a *auditor) error {
+		return multiClusterAudit(ctx, a.r, a.dynamicClient, a.discoveryClient)
+	}},
+	{"Namespaces with PVC", rpoPermissions, func(ctx context.Context, a *auditor) error {
+		index, err := a.actionIndex(ctx)
+		if err != nil {
+			return err
+		}
+		a.namespacesWithPVCs, err = rpoForNamespaceWithPVC(ctx, a.r, a.corev1Client, index, a.kastenNamespace)
+		return err
+	}},
+	{"Namespaces without PVC", rpoPermissions, func(ctx context.Context, a *auditor) error {
+		index, err := a.actionIndex(ctx)
+		if err != nil {
+			return err
+		}
+		return rpoForNamespaceWithoutPVC(ctx, a.r, a.corev1Client, index, a.namespacesWithPVCs)
+	}},
+	{"Failure causes", actionsPermissions, func(ctx context.Context, a *auditor) error {
+		index, err := a.actionIndex(ctx)
+		if err != nil {
+			return err
+		}
+		failuresAudit(a.r, index)
+		return nil
+	}},
+	{"Backup durations", durationsPermissions, func(ctx context.Context, a *auditor) error {
+		index, err := a.actionIndex(ctx)
+		if err != nil {
+			return err
+		}
+		return durationsAudit(ctx, a.r, a.dynamicClient, a.kastenNamespace, index)
+	}},
+	{"Blueprints and blueprint bindings", blueprintsPermissions, func(ctx context.Context, a *auditor) error {
+		return blueprintsAudit(ctx, a.r, a.dynamicClient, a.metadataClient, a.discoveryClient, a.kastenNamespace)
+	}},
+	{"Database workloads", databasePermissions, func(ctx context.Context, a *auditor) error {
+		index, err := a.actionIndex(ctx)
+		if err != nil {
+			return err
+		}
+		return databaseAudit(ctx, a.r, a.corev1Client, a.dynamicClient, a.kastenNamespace, index)
+	}},
+}
+
+var helmPermissions = []permission.Permission{
+	{Resource: "secrets", Verb: "list", KastenNamespace: true},
+	{Resource: "secrets", Verb: "get", KastenNamespace: true},
+}
+
+var clusterVersionResource = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusterversions"}
+
+var clusterInfoPermissions = []permission.Permission{
+	{Resource: "nodes", Verb: "list"},
+	{Group: clusterVersionResource.Group, Resource: clusterVersionResource.Resource, Verb: "get", Optional: true},
+}
+
+// kastenInstallPermissions are also needed by the checks using the helm
+// release it reads.
+var kastenInstallPermissions = permission.Join([]permission.Permission{
+	{Resource: "namespaces", Verb: "get"},
+	{Resource: "pods", Verb: "list", KastenNamespace: true},
+	{Group: "apps", Resource: "deployments", Verb: "list", KastenNamespace: true},
+}, helmPermissions)
+
+var profilesPermissions = []permission.Permission{
+	{Group: profile.GroupName, Resource: "profiles", Verb: "list", KastenNamespace: true},
+}
+
+var rpoPermissions = permission.Join([]permission.Permission{
+	{Resource: "namespaces", Verb: "list"},
+	{Resource: "persistentvolumeclaims", Verb: "list"},
+}, actionsPermissions)
+
 func main() {
+	kubeconfig := flag.String("kubeconfig", "", "kubeconfig file of the contexts, the default loading rules are used when empty")
+	contexts := flag.String("context", "", "comma separated kubeconfig contexts audited in parallel")
+	allContexts := flag.Bool("all-contexts", false, "audit every context of the kubeconfig in parallel")
+	flag.Parse()
 	kastenNamespace, kastenRelease := getKastenNamespaceAndRelease()
 
+	if flag.Arg(0) == "rbac" {
+		manifest, err := permission.Manifest(serviceAccountName, kastenNamespace, manifestPermissions())
+		if err != nil {
+			panic(err)
+		}
+		os.Stdout.Write(manifest)
+		return
+	}
+
+	switch flag.Arg(0) {
+	case "collect":
+		path := flag.Arg(1)
+		if path == "" {
+			path = "audit-snapshot.tar.gz"
+		}
+		collect(path, kastenNamespace, kastenRelease)
+		return
+	case "diff":
+		store, err := historyStore(kastenNamespace, nil)
+		if err != nil {
+			panic(err)
+		}
+		if store == nil {
+			panic("diff needs a history, KASTEN_HISTORY is none")
+		}
+		err = diffRuns(context.Background(), report.New(os.Stdout), store, flag.Args()[1:])
+		if err != nil {
+			panic(err)
+		}
+		return
+	case "analyze":
+		if flag.Arg(1) == "" {
+			panic("analyze needs the path of the tarball written by collect")
+		}
+		analyze(flag.Arg(1))
+		return
+	}
+
+	if *allContexts || *contexts != "" {
+		names := strings.Split(*contexts, ",")
+		if *allContexts {
+			var err error
+			names, err = client.Contexts(*kubeconfig)
+			if err != nil {
+				panic(err)
+			}
+		}
+		fleetAudit(context.Background(), os.Stdout, *kubeconfig, names, kastenNamespace, kastenRelease)
+		return
+	}
+
 	// create the necessary client
 	config, err := client.Config()
 	if err != nil {
 		panic(err.Error())
 	}
-	corev1Client, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		panic(err.Error())
-	}
-
-	actionClient, err := client.ActionClient(config)
+	a, err := newAuditor(config, report.New(os.Stdout), kastenNamespace, kastenRelease)
 	if err != nil {
 		panic(err)
 	}
-
-	profileClient, err := client.ProfileClient(config)
+	ctx := context.Background()
+	err = a.run(ctx)
 	if err != nil {
 		panic(err)
 	}
-
-	discoveryClient, err := client.DiscoveryClient(config)
-	if err != nil {
-		panic(err)
+	store, err := historyStore(kastenNamespace, a.corev1Client)
+	if err == nil && store != nil {
+		err = saveRun(ctx, store, a.r)
 	}
-
-	helmClient, err := client.HelmClient(config, kastenNamespace)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "the result of the audit could not be kept in the history: %s \n", err)
 	}
+}
+
+// newAuditor creates the clients of the cluster, the report receives the
+// result of the checks.
+func newAuditor(config *rest.Config, r *report.Report, kastenNamespace string, kastenRelease string) (*auditor, error) {
+	config = rest.CopyConfig(config)
+	config.QPS = float32(getEnvInt("KASTEN_QPS", defaultQPS))
+	config.Burst = getEnvInt("KASTEN_BURST", defaultBurst)
 
-	err = clusterInfo(corev1Client, discoveryClient)
+	corev1Client, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	// audits
-	err = checkKastenInstall(corev1Client, kastenNamespace, kastenRelease, helmClient)
+	discoveryClient, err := client.DiscoveryClient(config)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	err = profilesAudit(profileClient, kastenNamespace)
+	metadataClient, err := client.MetadataClient(config)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	namespacesWithPVCs, err := rpoForNamespaceWithPVC(corev1Client, actionClient, kastenNamespace)
+	dynamicClient, err := client.DynamicClient(config)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	err = rpoForNamespaceWithoutPVC(corev1Client, actionClient, namespacesWithPVCs)
+	helmClient, err := client.HelmClient(config, kastenNamespace)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
+	return &auditor{
+		r:               r,
+		kastenNamespace: kastenNamespace,
+		kastenRelease:   kastenRelease,
+		corev1Client:    corev1Client,
+		discoveryClient: discoveryClient,
+		metadataClient:  metadataClient,
+		dynamicClient:   dynamicClient,
+		helmClient:      helmClient,
+	}, nil
+}
+
+// run runs the checks in order, a check is skipped when the permissions it
+// needs are missing.
+func (a *auditor) run(ctx context.Context) error {
+	for _, c := range checks {
+		missing, err := permission.Missing(ctx, a.corev1Client, a.kastenNamespace, c.permissions)
+		if err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			a.r.Section(c.title)
+			for _, p := range missing {
+				a.r.WarningFor(p.String(), "insufficient permission, the check is skipped: %s is needed", p)
+			}
+			continue
+		}
+		err = c.run(ctx, a)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func checkKastenInstall(corev1Client *kubernetes.Clientset, kastenNamespace string, kastenRelease string, helmClient helm.Client) error {
-	fmt.Println("")
-	fmt.Println("=======================")
-	fmt.Println("Checking Kasten install")
-	fmt.Println("=======================")
-	fmt.Printf("  checking if Kasten is installed in namespace %s under the release %s \n", kastenNamespace, kastenRelease)
+func checkKastenInstall(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface, kastenNamespace string, kastenRelease string, helmClient releaseGetter, cluster clusterFacts) (*release.Release, error) {
+	r.Section("Checking Kasten install")
+	r.Printf("  checking if Kasten is installed in namespace %s under the release %s \n", kastenNamespace, kastenRelease)
 	//ns kasten exist
-	_, err := corev1Client.CoreV1().Namespaces().Get(context.TODO(), kastenNamespace, metav1.GetOptions{})
+	_, err := corev1Client.CoreV1().Namespaces().Get(ctx, kastenNamespace, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
-		fmt.Printf("  --> WARNING !! %s namespace not found, kasten is maybe installed in another namespace \n", kastenNamespace)
-		return fmt.Errorf("%s namespace not found, kasten is maybe installed in another namespace", kastenNamespace)
+		r.Warning("%s namespace not found, kasten is maybe installed in another namespace", kastenNamespace)
+		return nil, fmt.Errorf("%s namespace not found, kasten is maybe installed in another namespace", kastenNamespace)
 	}
 	//release exist
 	release, err := helmClient.GetRelease(kastenRelease)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("  The version of kasten is %s \n", release.Chart.Metadata.AppVersion)
-	//all pods healthy
-	results, err := corev1Client.CoreV1().Pods(kastenNamespace).List(context.TODO(), metav1.ListOptions{})
+	r.Printf("  The version of kasten is %s \n", release.Chart.Metadata.AppVersion)
+	r.Summary.KastenVersion = release.Chart.Metadata.AppVersion
+	err = kastenSupportAudit(r, release.Chart.Metadata.AppVersion, cluster)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	podsInError := false
-	for _, pod := range results.Items {
-		for _, c := range pod.Status.ContainerStatuses {
-			if c.State.Waiting != nil && c.State.Waiting.Reason == "CrashLoopBackOff" {
-				podsInError = true
-				fmt.Printf("  pod %s is in error: %s\n", pod.ObjectMeta.Name, pod.Status.Phase)
-			}
-		}
+	values, err := helmValues(release)
+	if err != nil {
+		return nil, err
 	}
-	if podsInError {
-		fmt.Printf("  --> WARNING !! some pods in the kasten namespace %s are on error \n", kastenNamespace)
-	} else {
-		fmt.Printf("  --> No pods in the kasten namespace %s are on error \n", kastenNamespace)
+	//all pods and deployments healthy
+	pods, err := listPods(ctx, corev1Client, kastenNamespace)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	deployments, err := corev1Client.AppsV1().Deployments(kastenNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	expected := expectedDeployments(release.Chart.Metadata.AppVersion, values)
+	restartThreshold := getEnvInt("KASTEN_RESTART_THRESHOLD", defaultRestartThreshold)
+	kastenWorkloadsAudit(r, pods, deployments.Items, expected, kastenNamespace, restartThreshold)
+	return release, nil
 }
 
-func profilesAudit(profileClient *rest.RESTClient, kastenNamespace string) error {
+func profilesAudit(ctx context.Context, r *report.Report, dynamicClient dynamic.Interface, kastenNamespace string) error {
 
-	fmt.Println("")
-	fmt.Println("=================")
-	fmt.Println("Auditing profiles")
-	fmt.Println("=================")
+	r.Section("Auditing profiles")
 
 	result := profile.ProfileList{}
-	err := profileClient.
-		Get().
-		Resource("profiles").Namespace(kastenNamespace).
-		Do(context.TODO()).
-		Into(&result)
+	err := client.List(ctx, dynamicClient, profile.ProfileResource, kastenNamespace, &result)
 	if err != nil {
 		return err
 	} else {
 		if len(result.Items) == 0 {
-			fmt.Println("  -> WARNING !! there is no profile at all, you don't have real backup ")
+			r.Warning("there is no profile at all, you don't have real backup")
 			return nil
 		}
 		foundLocationProfile := false
@@ -165,94 +422,102 @@ func profilesAudit(profileClient *rest.RESTClient, kastenNamespace string) error
 				}
 			}
 			if profileInKasten.Status.Validation != "Success" {
-				fmt.Printf("  --> WARNING !! found profile %s which is not valid\n", profileInKasten.Name)
+				r.WarningFor(profileInKasten.Name, "found profile %s which is not valid", profileInKasten.Name)
 			}
 		}
 		if !foundLocationProfile {
-			fmt.Println("  --> WARNING !! there is no location profile at all, you don't have real backup ")
+			r.Warning("there is no location profile at all, you don't have real backup")
 		} else {
-			fmt.Println("  At least one location profile was found")
+			r.Println("  At least one location profile was found")
 		}
+		r.Summary.Immutable = foundImmutable
 		if foundLocationProfile && !foundImmutable {
-			fmt.Println("  --> WARNING !! there is no immutable profile your are not protected against Ransomware ")
+			r.Warning("there is no immutable profile your are not protected against Ransomware")
 		}
 	}
 	return nil
 }
 
-func clusterInfo(corev1Client *kubernetes.Clientset, discoveryClient *discovery.DiscoveryClient) error {
-	fmt.Println("")
-	fmt.Println("=============================")
-	fmt.Println("Information about the cluster")
-	fmt.Println("=============================")
+func clusterInfo(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface, discoveryClient discovery.DiscoveryInterface, dynamicClient dynamic.Interface) (clusterFacts, error) {
+	r.Section("Information about the cluster")
+	facts := clusterFacts{}
 	information, err := discoveryClient.ServerVersion()
 	if err != nil {
-		return err
+		return facts, err
+	}
+	facts.kubernetesVersion = information.GitVersion
+	r.Printf("  Kubernetes version : %s.%s \n", information.Major, information.Minor)
+	r.Printf("  Platform : %s \n", information.Platform)
+
+	clusterVersion, err := dynamicClient.Resource(clusterVersionResource).Get(ctx, "version", metav1.GetOptions{})
+	if err == nil {
+		facts.openshiftVersion, _, _ = unstructured.NestedString(clusterVersion.Object, "status", "desired", "version")
+		r.Printf("  OpenShift version : %s \n", facts.openshiftVersion)
+	} else if !errors.IsNotFound(err) && !errors.IsForbidden(err) {
+		return facts, err
 	}
-	fmt.Printf("  Kubernetes version : %s.%s \n", information.Major, information.Minor)
-	fmt.Printf("  Platform : %s \n", information.Platform)
 
-	nodes, err := corev1Client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	nodes, err := listNodes(ctx, corev1Client)
 	if err != nil {
-		return err
+		return facts, err
 	}
-	numNodes := len(nodes.Items)
-	fmt.Printf("  There are %d nodes in this cluster, looking for nodes in error\n", numNodes)
+	numNodes := len(nodes)
+	facts.nodes = numNodes
+	r.Printf("  There are %d nodes in this cluster, looking for nodes in error\n", numNodes)
 	nodeInError := false
-	for _, node := range nodes.Items {
+	for _, node := range nodes {
 		for _, condition := range node.Status.Conditions {
 			if condition.Type == v1.NodeReady && condition.Status != v1.ConditionTrue {
 				nodeInError = true
-				fmt.Printf("  NodName: %s, Condition type: %s %s", node.Name, condition.Type, condition.Status)
+				r.Printf("  NodName: %s, Condition type: %s %s", node.Name, condition.Type, condition.Status)
 			}
 		}
 	}
 	if !nodeInError {
-		fmt.Printf("  --> No nodes are in error\n")
+		r.Printf("  --> No nodes are in error\n")
 	} else {
-		fmt.Printf("  --> Some nodes are in error\n")
+		r.Printf("  --> Some nodes are in error\n")
 	}
 
-	return nil
+	return facts, nil
 }
 
-func rpoForNamespaceWithoutPVC(corev1Client *kubernetes.Clientset, actionClient *rest.RESTClient, namespacesWithPVCs map[string][]string) error {
+func rpoForNamespaceWithoutPVC(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface, actions *actionIndex, namespacesWithPVCs map[string][]string) error {
 	var namespacesWithoutPVCs []string
-	namespaces, err := corev1Client.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		return err
-	}
-	for _, namespace := range namespaces.Items {
+	_, err := client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.CoreV1().Namespaces().List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		namespace := object.(*v1.Namespace)
 		_, ok := namespacesWithPVCs[namespace.Name]
 		if !ok {
 			namespacesWithoutPVCs = append(namespacesWithoutPVCs, namespace.Name)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	log.WithFields(log.Fields{
 		"namespacesWithoutPVCs": namespacesWithoutPVCs,
 	}).Info("namespace without pvcs")
 
-	fmt.Println("")
-	fmt.Println("======================")
-	fmt.Println("Namespaces without PVC")
-	fmt.Println("======================")
+	r.Section("Namespaces without PVC")
 
-	for _, namespace := range namespacesWithoutPVCs {
-		fmt.Printf("%s has no PVC\n", namespace)
-		rpo(namespace, actionClient)
-	}
+	scanned := auditNamespaces(r, namespacesWithoutPVCs, func(namespace string) string {
+		return namespace + " has no PVC"
+	}, actions)
+	r.Printf("  --> %d namespaces and %d backupactions scanned \n", len(namespacesWithoutPVCs), scanned)
 	return nil
 }
 
-func rpoForNamespaceWithPVC(corev1Client *kubernetes.Clientset, actionClient *rest.RESTClient, kastenNamespace string) (map[string][]string, error) {
+func rpoForNamespaceWithPVC(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface, actions *actionIndex, kastenNamespace string) (map[string][]string, error) {
 	//list all namespaces that has pvc
-	pvcs, err := corev1Client.CoreV1().PersistentVolumeClaims("").List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		return map[string][]string{}, err
-	}
 	namespacesWithPVCs := make(map[string][]string)
-	for _, pvc := range pvcs.Items {
+	scannedPVCs, err := client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.CoreV1().PersistentVolumeClaims("").List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		pvc := object.(*v1.PersistentVolumeClaim)
 		pvcsInNamespace, ok := namespacesWithPVCs[pvc.Namespace]
 		if ok {
 			namespacesWithPVCs[pvc.Namespace] = append(pvcsInNamespace, pvc.Name)
@@ -261,65 +526,110 @@ func rpoForNamespaceWithPVC(corev1Client *kubernetes.Clientset, actionClient *re
 			pvcsInNamespace = append(pvcsInNamespace, pvc.Name)
 			namespacesWithPVCs[pvc.Namespace] = pvcsInNamespace
 		}
+		return nil
+	})
+	if err != nil {
+		return map[string][]string{}, err
 	}
 
 	log.WithFields(log.Fields{
 		"namespacesWithPVCs": namespacesWithPVCs,
 	}).Info("namespace with pvcs")
 
-	fmt.Println("")
-	fmt.Println("===================")
-	fmt.Println("Namespaces with PVC")
-	fmt.Println("===================")
-	for namespace, pvcs := range namespacesWithPVCs {
-		if namespace == kastenNamespace {
-			continue
+	r.Section("Namespaces with PVC")
+	var namespaces []string
+	for namespace := range namespacesWithPVCs {
+		if namespace != kastenNamespace {
+			namespaces = append(namespaces, namespace)
 		}
-		fmt.Printf("%s has %d PVCs\n", namespace, len(pvcs))
-		rpo(namespace, actionClient)
 	}
+	scanned := auditNamespaces(r, namespaces, func(namespace string) string {
+		return fmt.Sprintf("%s has %d PVCs", namespace, len(namespacesWithPVCs[namespace]))
+	}, actions)
+	r.Printf("  --> %d PVCs and %d backupactions scanned, %d actions listed cluster wide \n", scannedPVCs, scanned, actions.scanned)
 	return namespacesWithPVCs, nil
 }
 
-func rpo(namespace string, actionClient *rest.RESTClient) error {
-	result := action.BackupActionList{}
-	err := actionClient.
-		Get().
-		Resource("backupactions").Namespace(namespace).
-		Do(context.TODO()).
-		Into(&result)
-	if err != nil {
-		return err
+// rpo shows the most recent backupactions of the namespace, at most
+// KASTEN_MAX_ACTIONS, and returns how many were scanned. The API does not
+// sort the actions so they can't be limited in the list call, they are
+// sorted here by their scheduled time and the RPO comes from the successful
+// action which ended last.
+func rpo(r *report.Report, namespace string, actions namespaceActions) int {
+	backupActions := actions.backups
+	sortBackupActions(backupActions)
+	if len(backupActions) == 0 {
+		r.Printf("  --> No backupactions in namespace %s \n", namespace)
+		r.Summary.UnprotectedNamespaces++
+		r.Summary.SetUnprotected(namespace)
 	} else {
-		if len(result.Items) == 0 {
-			fmt.Printf("  --> No backupactions in namespace %s \n", namespace)
+		padding := "  %-30s %-10s %-30s %-30s \n"
+		maxActions := getEnvInt("KASTEN_MAX_ACTIONS", defaultMaxActions)
+		if maxActions < 0 {
+			maxActions = 0
+		}
+		r.Printf(padding, "BACKUPACTION", "STATE", "START", "STOP")
+		for i, backupAction := range backupActions {
+			if i < maxActions {
+				r.Printf(padding, backupAction.Name, backupAction.Status.State, backupAction.Start(), backupAction.Status.EndTime)
+			}
+		}
+		if len(backupActions) > maxActions {
+			r.Printf("  ... %d older backupactions not shown \n", len(backupActions)-maxActions)
+		}
+		stats := computeBackupStats(backupActions)
+		if stats.last == nil {
+			r.WarningFor(namespace, "It seems that no backupaction were successful in namespace %s", namespace)
+			r.Summary.UnprotectedNamespaces++
+			r.Summary.SetUnprotected(namespace)
 		} else {
-			padding := "  %-30s %-10s %-30s %-30s \n"
-			first := true
-			completeBackupAction := false
-			var rpoMessage string
-			fmt.Printf(padding, "BACKUPACTION", "STATE", "START", "STOP")
-			for _, backupAction := range result.Items {
-				if first && backupAction.Status.State == "Complete" {
-					first = false
-					completeBackupAction = true
-					now := time.Now()
-					rpoDuration := now.Sub(backupAction.Status.EndTime)
-					days := int64(rpoDuration.Hours() / 24)
-					hours := int64(rpoDuration.Hours()) % 24
-					rpoMessage = fmt.Sprintf("  --> The last RPO is %d days and %d hours", days, hours)
-				}
-				fmt.Printf(padding, backupAction.Name, backupAction.Status.State, backupAction.CreationTimestamp, backupAction.Status.EndTime)
+			rpoDuration := now().Sub(stats.last.Status.EndTime)
+			if rpoDuration > r.Summary.WorstRPO {
+				r.Summary.WorstRPO = rpoDuration
 			}
-			if !completeBackupAction {
-				fmt.Println("  --> WARNING !! It seems that no backupaction were successful")
-			} else {
-				fmt.Println(rpoMessage)
+			r.Summary.SetRPO(namespace, rpoDuration)
+			days := int64(rpoDuration.Hours() / 24)
+			hours := int64(rpoDuration.Hours()) % 24
+			r.Printf("  --> The last RPO is %d days and %d hours\n", days, hours)
+			r.Printf("  --> %d%% of the finished backupactions succeeded (%d/%d), mean duration %s \n",
+				stats.successRate(), stats.complete, stats.complete+stats.failed, stats.meanDuration.Round(time.Second))
+			if stats.failureStreak >= getEnvInt("KASTEN_FAILURE_STREAK", defaultFailureStreak) {
+				r.WarningFor(namespace, "the last %d backupactions of namespace %s failed", stats.failureStreak, namespace)
 			}
-
 		}
 	}
-	return nil
+	if len(actions.exports) > 0 || len(actions.restores) > 0 {
+		exported, restored := 0, 0
+		for _, exportAction := range actions.exports {
+			if exportAction.Status.State == "Complete" {
+				exported++
+			}
+		}
+		for _, restoreAction := range actions.restores {
+			if restoreAction.Status.State == "Complete" {
+				restored++
+			}
+		}
+		r.Printf("  %d exportactions (%d complete) and %d restoreactions (%d complete) \n", len(actions.exports), exported, len(actions.restores), restored)
+	}
+	return len(backupActions)
+}
+
+// manifestPermissions are the permissions the rbac command grants, those of
+// every check and the opt-in ones asked with KASTEN_HISTORY=configmap and
+// KASTEN_NODE_PROXY=true.
+func manifestPermissions() []permission.Permission {
+	var permissions []permission.Permission
+	for _, c := range checks {
+		permissions = append(permissions, c.permissions...)
+	}
+	if os.Getenv("KASTEN_HISTORY") == "configmap" {
+		permissions = append(permissions, historyPermissions...)
+	}
+	if nodeProxy, _ := strconv.ParseBool(os.Getenv("KASTEN_NODE_PROXY")); nodeProxy {
+		permissions = append(permissions, nodeProxyPermissions...)
+	}
+	return permissions
 }
 
 func getKastenNamespaceAndRelease() (string, string) {
@@ -333,3 +643,13 @@ func getKastenNamespaceAndRelease() (string, string) {
 	}
 	return namespace, release
 }
+
+// getEnvInt returns the integer value of the environment variable or def when
+// it is not set or not an integer.
+func getEnvInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return value
+}
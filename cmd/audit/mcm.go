@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/policy"
+	"github.com/michaelcourcy/audit-tool/pkg/profile"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// defaultMultiClusterNamespace holds the clusters, the distributions and
+	// the global policies and profiles of a multi-cluster primary, it can be
+	// changed with KASTEN_MC_NAMESPACE.
+	defaultMultiClusterNamespace = "kasten-io-mc"
+	// allClustersLabel is the selector a distribution uses to target every
+	// joined cluster.
+	allClustersLabel = "dist.kio.kasten.io/cluster-type"
+)
+
+var (
+	clusterResource      = schema.GroupVersionResource{Group: "dist.kio.kasten.io", Version: "v1alpha1", Resource: "clusters"}
+	distributionResource = schema.GroupVersionResource{Group: "dist.kio.kasten.io", Version: "v1alpha1", Resource: "distributions"}
+)
+
+// multiClusterPermissions are cluster wide because the multi-cluster
+// namespace is not the kasten namespace, the global policies and profiles are
+// optional.
+var multiClusterPermissions = []permission.Permission{
+	{Group: clusterResource.Group, Resource: clusterResource.Resource, Verb: "list"},
+	{Group: distributionResource.Group, Resource: distributionResource.Resource, Verb: "list"},
+	{Group: policy.PolicyResource.Group, Resource: policy.PolicyResource.Resource, Verb: "list", Optional: true},
+	{Group: profile.ProfileResource.Group, Resource: profile.ProfileResource.Resource, Verb: "list", Optional: true},
+}
+
+// distributedResource is a global policy or profile a distribution sends to
+// the secondaries.
+type distributedResource struct {
+	resource string
+	name     string
+}
+
+func multiClusterAudit(ctx context.Context, r *report.Report, dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface) error {
+	r.Section("Multi-cluster manager")
+
+	_, err := discoveryClient.ServerResourcesForGroupVersion(clusterResource.GroupVersion().String())
+	if err != nil {
+		r.Println("  This cluster is not a Kasten multi-cluster primary")
+		return nil
+	}
+	namespace := os.Getenv("KASTEN_MC_NAMESPACE")
+	if namespace == "" {
+		namespace = defaultMultiClusterNamespace
+	}
+
+	clusters, err := listObjects(ctx, dynamicClient, clusterResource, namespace)
+	if err != nil {
+		return err
+	}
+	if len(clusters) == 0 {
+		r.Println("  The multi-cluster manager is installed but no cluster is joined")
+		return nil
+	}
+	distributions, err := listObjects(ctx, dynamicClient, distributionResource, namespace)
+	if err != nil {
+		return err
+	}
+
+	// global resources, nil when they can't be listed
+	globals := map[string]map[string]bool{}
+	for _, gvr := range []schema.GroupVersionResource{policy.PolicyResource, profile.ProfileResource} {
+		list, err := listObjects(ctx, dynamicClient, gvr, namespace)
+		if errors.IsForbidden(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		globals[gvr.Resource] = map[string]bool{}
+		for _, item := range list {
+			globals[gvr.Resource][item.GetName()] = true
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].GetName() < clusters[j].GetName() })
+	padding := "  %-30s %-10s %-20s %-60s \n"
+	r.Printf(padding, "CLUSTER", "PRIMARY", "STATUS", "DISTRIBUTED")
+	hasPrimary := false
+	for _, cluster := range clusters {
+		primary, _, _ := unstructured.NestedBool(cluster.Object, "spec", "primary")
+		hasPrimary = hasPrimary || primary
+		status, message := objectStatus(cluster)
+		var distributed []string
+		for _, distribution := range distributions {
+			if !distributionTargets(distribution, cluster) {
+				continue
+			}
+			for _, resource := range distributedResources(distribution) {
+				distributed = append(distributed, resource.resource+"/"+resource.name)
+			}
+		}
+		sort.Strings(distributed)
+		r.Printf(padding, cluster.GetName(), yesNo(primary), status, strings.Join(distributed, ","))
+		if !primary && !healthyStatus(status) {
+			r.WarningFor(cluster.GetName(), "secondary cluster %s is %s: %s", cluster.GetName(), status, message)
+		}
+		if !primary && len(distributed) == 0 {
+			r.WarningFor(cluster.GetName(), "secondary cluster %s receives no global policy or profile", cluster.GetName())
+		}
+	}
+
+	if !hasPrimary {
+		r.Warning("no cluster of %s is the primary, the secondaries are not managed", namespace)
+	}
+
+	for _, distribution := range distributions {
+		// a distribution without status was not reconciled yet
+		if status, message := objectStatus(distribution); status != "Unknown" && !healthyStatus(status) {
+			r.WarningFor(distribution.GetName(), "distribution %s is %s: %s", distribution.GetName(), status, message)
+		}
+		for _, resource := range distributedResources(distribution) {
+			if names, ok := globals[resource.resource]; ok && !names[resource.name] {
+				r.WarningFor(distribution.GetName()+"/"+resource.resource+"/"+resource.name, "distribution %s distributes %s %s which does not exist in %s", distribution.GetName(), resource.resource, resource.name, namespace)
+			}
+		}
+		for _, drift := range distributionDrifts(distribution) {
+			r.WarningFor(distribution.GetName()+"/"+drift, "distribution %s has drifted on cluster %s", distribution.GetName(), drift)
+		}
+	}
+	return nil
+}
+
+// listObjects lists the objects of the multi-cluster namespace page by page.
+func listObjects(ctx context.Context, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, namespace string) ([]unstructured.Unstructured, error) {
+	var objects []unstructured.Unstructured
+	_, err := client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		objects = append(objects, *object.(*unstructured.Unstructured))
+		return nil
+	})
+	return objects, err
+}
+
+// objectStatus returns the state of a multi-cluster object from its Ready or
+// Connected condition, or from its status.state.
+func objectStatus(object unstructured.Unstructured) (string, string) {
+	conditions, _, _ := unstructured.NestedSlice(object.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Ready" || condition["type"] == "Connected" {
+			message, _ := condition["message"].(string)
+			if condition["status"] == "True" {
+				return fmt.Sprint(condition["type"]), message
+			}
+			return "Not" + fmt.Sprint(condition["type"]), message
+		}
+	}
+	state, _, _ := unstructured.NestedString(object.Object, "status", "state")
+	message, _, _ := unstructured.NestedString(object.Object, "status", "error")
+	if state == "" {
+		state = "Unknown"
+	}
+	return state, message
+}
+
+func healthyStatus(status string) bool {
+	switch status {
+	case "Ready", "Connected", "Success", "Succeeded", "Synced":
+		return true
+	}
+	return false
+}
+
+// distributionTargets tells if one of the selectors of spec.clusters matches
+// the labels of the cluster.
+func distributionTargets(distribution unstructured.Unstructured, cluster unstructured.Unstructured) bool {
+	selectors, _, _ := unstructured.NestedSlice(distribution.Object, "spec", "clusters")
+	for _, s := range selectors {
+		selector, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if value, ok := selector[allClustersLabel]; ok && value == "all" {
+			return true
+		}
+		matches := len(selector) > 0
+		for key, value := range selector {
+			if cluster.GetLabels()[key] != fmt.Sprint(value) {
+				matches = false
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func distributedResources(distribution unstructured.Unstructured) []distributedResource {
+	var resources []distributedResource
+	items, _, _ := unstructured.NestedSlice(distribution.Object, "spec", "resources")
+	for _, i := range items {
+		item, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resource, _ := item["resource"].(string)
+		name, _ := item["name"].(string)
+		resources = append(resources, distributedResource{resource: resource, name: name})
+	}
+	return resources
+}
+
+// distributionDrifts returns the clusters where the distributed configuration
+// is not in sync, from the per cluster status of the distribution.
+func distributionDrifts(distribution unstructured.Unstructured) []string {
+	var drifts []string
+	items, _, _ := unstructured.NestedSlice(distribution.Object, "status", "clusters")
+	for _, i := range items {
+		item, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := item["name"].(string)
+		state, _ := item["state"].(string)
+		if state != "" && !healthyStatus(state) {
+			message, _ := item["error"].(string)
+			drifts = append(drifts, fmt.Sprintf("%s (%s %s)", name, state, message))
+		}
+	}
+	return drifts
+}
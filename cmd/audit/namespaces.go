@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+)
+
+const (
+	// defaultConcurrency is the number of namespaces audited at the same
+	// time, it can be changed with KASTEN_CONCURRENCY.
+	defaultConcurrency = 10
+	// defaultQPS and defaultBurst replace the low defaults of client-go so
+	// the workers are not throttled on the client side, they can be changed
+	// with KASTEN_QPS and KASTEN_BURST.
+	defaultQPS   = 50
+	defaultBurst = 100
+)
+
+// auditNamespaces gives the RPO of the namespaces with KASTEN_CONCURRENCY
+// workers. Every namespace prints in its own buffered report, appended in the
+// order of the namespaces so the report does not depend on which worker was
+// faster. It returns the number of backupactions scanned.
+func auditNamespaces(r *report.Report, namespaces []string, header func(namespace string) string, actions *actionIndex) int {
+	sort.Strings(namespaces)
+	audits := make([]*report.Report, len(namespaces))
+	scanned := make([]int, len(namespaces))
+
+	workers := getEnvInt("KASTEN_CONCURRENCY", defaultConcurrency)
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				buffered := r.Buffer()
+				buffered.Println(header(namespaces[i]))
+				audits[i], scanned[i] = buffered, rpo(buffered, namespaces[i], actions.get(namespaces[i]))
+			}
+		}()
+	}
+	for i := range namespaces {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	total := 0
+	for i := range audits {
+		r.Append(audits[i])
+		total += scanned[i]
+	}
+	return total
+}
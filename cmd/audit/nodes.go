@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultSupportedArchs are the node architectures the Kasten images are
+// built for, it can be changed with KASTEN_SUPPORTED_ARCHS, "amd64,arm64".
+const defaultSupportedArchs = "amd64"
+
+// workerRequests is what a Kasten data mover or Kanister pod needs at least
+// to be scheduled on a node.
+var workerRequests = v1.ResourceList{
+	v1.ResourceCPU:    resource.MustParse("100m"),
+	v1.ResourceMemory: resource.MustParse("256Mi"),
+}
+
+var nodeCapacityPermissions = []permission.Permission{
+	{Resource: "nodes", Verb: "list"},
+	{Resource: "pods", Verb: "list"},
+}
+
+func nodeCapacityAudit(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface) error {
+	r.Section("Node capacity for Kasten workers")
+
+	nodes, err := listNodes(ctx, corev1Client)
+	if err != nil {
+		return err
+	}
+	requested := map[string]v1.ResourceList{}
+	_, err = client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.CoreV1().Pods("").List(ctx, opts)
+	}, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	}, func(object runtime.Object) error {
+		pod := object.(*v1.Pod)
+		if pod.Spec.NodeName == "" {
+			return nil
+		}
+		if requested[pod.Spec.NodeName] == nil {
+			requested[pod.Spec.NodeName] = v1.ResourceList{}
+		}
+		addRequests(requested[pod.Spec.NodeName], *pod)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	supported := map[string]bool{}
+	archs := os.Getenv("KASTEN_SUPPORTED_ARCHS")
+	if archs == "" {
+		archs = defaultSupportedArchs
+	}
+	for _, arch := range strings.Split(archs, ",") {
+		supported[strings.TrimSpace(arch)] = true
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	padding := "  %-40s %-8s %-12s %-12s %-8s %-50s \n"
+	r.Printf(padding, "NODE", "ARCH", "FREE CPU", "FREE MEMORY", "WORKERS", "REASON")
+	landing := 0
+	for _, node := range nodes {
+		free := v1.ResourceList{}
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			quantity := node.Status.Allocatable[name].DeepCopy()
+			quantity.Sub(requested[node.Name][name])
+			free[name] = quantity
+		}
+		reasons := nodeBlockers(node, free, supported)
+		arch := node.Labels[v1.LabelArchStable]
+		if len(reasons) == 0 {
+			landing++
+			r.Printf(padding, node.Name, arch, free.Cpu().String(), free.Memory().String(), "yes", "-")
+		} else {
+			r.Printf(padding, node.Name, arch, free.Cpu().String(), free.Memory().String(), "no", strings.Join(reasons, ","))
+		}
+		for _, condition := range node.Status.Conditions {
+			switch condition.Type {
+			case v1.NodeMemoryPressure, v1.NodeDiskPressure, v1.NodePIDPressure:
+				if condition.Status == v1.ConditionTrue {
+					r.WarningFor(node.Name+"/"+string(condition.Type), "node %s has %s: %s", node.Name, condition.Type, condition.Message)
+				}
+			}
+		}
+	}
+	if landing == 0 {
+		r.Critical("no node can run the Kasten data mover and Kanister pods, backups and restores of volumes will stay pending")
+	} else {
+		r.Printf("  --> Kasten workers can land on %d of the %d nodes \n", landing, len(nodes))
+	}
+	return nil
+}
+
+// listNodes reads the nodes in pages.
+func listNodes(ctx context.Context, corev1Client kubernetes.Interface) ([]v1.Node, error) {
+	var nodes []v1.Node
+	_, err := client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.CoreV1().Nodes().List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		nodes = append(nodes, *object.(*v1.Node))
+		return nil
+	})
+	return nodes, err
+}
+
+// nodeBlockers returns why a Kasten worker pod, which has no toleration and
+// no node selector by default, can't be scheduled on the node.
+func nodeBlockers(node v1.Node, free v1.ResourceList, supportedArchs map[string]bool) []string {
+	var reasons []string
+	if node.Spec.Unschedulable {
+		reasons = append(reasons, "cordoned")
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady && condition.Status != v1.ConditionTrue {
+			reasons = append(reasons, "not ready")
+		}
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == v1.TaintEffectNoSchedule || taint.Effect == v1.TaintEffectNoExecute {
+			reasons = append(reasons, fmt.Sprintf("taint %s:%s", taint.Key, taint.Effect))
+		}
+	}
+	if arch := node.Labels[v1.LabelArchStable]; arch != "" && !supportedArchs[arch] {
+		reasons = append(reasons, "arch "+arch)
+	}
+	if nodeOS := node.Labels[v1.LabelOSStable]; nodeOS != "" && nodeOS != "linux" {
+		reasons = append(reasons, "os "+nodeOS)
+	}
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		available, needed := free[name], workerRequests[name]
+		if available.Cmp(needed) < 0 {
+			reasons = append(reasons, "not enough "+string(name))
+		}
+	}
+	return reasons
+}
+
+// addRequests adds the requests of the containers of the pod, an init
+// container requesting more than all the containers sets the request.
+func addRequests(total v1.ResourceList, pod v1.Pod) {
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		sum := resource.Quantity{}
+		for _, c := range pod.Spec.Containers {
+			sum.Add(c.Resources.Requests[name])
+		}
+		for _, c := range pod.Spec.InitContainers {
+			if request := c.Resources.Requests[name]; request.Cmp(sum) > 0 {
+				sum = request.DeepCopy()
+			}
+		}
+		quantity := total[name]
+		quantity.Add(sum)
+		total[name] = quantity
+	}
+}
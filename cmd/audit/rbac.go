@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// kastenRoles are the cluster roles created by the Kasten chart.
+var kastenRoles = []string{"k10-admin", "k10-basic", "k10-config-view", "k10-mc-admin"}
+
+// adminRoles are the cluster roles which make a subject an administrator when
+// bound cluster wide.
+var adminRoles = map[string]bool{"cluster-admin": true, "k10-admin": true, "k10-mc-admin": true}
+
+// capabilities are what a subject can do with the backups of a namespace and
+// the verb on the Kasten resource which grants it.
+var capabilities = []struct {
+	name     string
+	group    string
+	resource string
+	verb     string
+}{
+	{"backup", "actions.kio.kasten.io", "backupactions", "create"},
+	{"restore", "actions.kio.kasten.io", "restoreactions", "create"},
+	{"delete", "apps.kio.kasten.io", "restorepoints", "delete"},
+}
+
+var rbacPermissions = []permission.Permission{
+	{Group: rbacv1.GroupName, Resource: "clusterroles", Verb: "list"},
+	{Group: rbacv1.GroupName, Resource: "roles", Verb: "list"},
+	{Group: rbacv1.GroupName, Resource: "clusterrolebindings", Verb: "list"},
+	{Group: rbacv1.GroupName, Resource: "rolebindings", Verb: "list"},
+}
+
+// grant is what a subject can do in a scope, a namespace or the whole cluster.
+type grant struct {
+	subject      string
+	scope        string
+	admin        bool
+	capabilities map[string]bool
+	roles        []string
+	wildcard     bool
+}
+
+func rbacAudit(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface, kastenNamespace string) error {
+	r.Section("Kasten RBAC")
+
+	rules := map[string][]rbacv1.PolicyRule{}
+	_, err := client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.RbacV1().ClusterRoles().List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		role := object.(*rbacv1.ClusterRole)
+		rules["ClusterRole/"+role.Name] = role.Rules
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.RbacV1().Roles("").List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		role := object.(*rbacv1.Role)
+		rules["Role/"+role.Namespace+"/"+role.Name] = role.Rules
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var clusterRoleBindings []rbacv1.ClusterRoleBinding
+	_, err = client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.RbacV1().ClusterRoleBindings().List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		clusterRoleBindings = append(clusterRoleBindings, *object.(*rbacv1.ClusterRoleBinding))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var roleBindings []rbacv1.RoleBinding
+	_, err = client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return corev1Client.RbacV1().RoleBindings("").List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		roleBindings = append(roleBindings, *object.(*rbacv1.RoleBinding))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range kastenRoles {
+		if _, ok := rules["ClusterRole/"+name]; ok {
+			r.Printf("  Kasten cluster role %s exists \n", name)
+		}
+	}
+
+	// subjects bound cluster wide to an admin role
+	admins := map[string]bool{}
+	for _, binding := range clusterRoleBindings {
+		if binding.RoleRef.Kind == "ClusterRole" && adminRoles[binding.RoleRef.Name] {
+			for _, subject := range binding.Subjects {
+				admins[subjectName(subject)] = true
+			}
+		}
+	}
+
+	grants := map[string]*grant{}
+	addGrant := func(subject rbacv1.Subject, scope string, roleKey string) {
+		name := subjectName(subject)
+		if ignoredSubject(subject) {
+			return
+		}
+		caps, wildcard := roleCapabilities(rules[roleKey])
+		if len(caps) == 0 {
+			return
+		}
+		key := name + "|" + scope
+		g, ok := grants[key]
+		if !ok {
+			admin := admins[name] || (subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == kastenNamespace)
+			g = &grant{subject: name, scope: scope, admin: admin, capabilities: map[string]bool{}}
+			grants[key] = g
+		}
+		for c := range caps {
+			g.capabilities[c] = true
+		}
+		g.roles = append(g.roles, roleKey)
+		g.wildcard = g.wildcard || wildcard
+	}
+	for _, binding := range clusterRoleBindings {
+		for _, subject := range binding.Subjects {
+			addGrant(subject, "*", "ClusterRole/"+binding.RoleRef.Name)
+		}
+	}
+	for _, binding := range roleBindings {
+		roleKey := "ClusterRole/" + binding.RoleRef.Name
+		if binding.RoleRef.Kind == "Role" {
+			roleKey = "Role/" + binding.Namespace + "/" + binding.RoleRef.Name
+		}
+		for _, subject := range binding.Subjects {
+			addGrant(subject, binding.Namespace, roleKey)
+		}
+	}
+
+	var sorted []*grant
+	for _, g := range grants {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].subject != sorted[j].subject {
+			return sorted[i].subject < sorted[j].subject
+		}
+		return sorted[i].scope < sorted[j].scope
+	})
+
+	padding := "  %-50s %-25s %-6s %-7s %-7s %-7s %-50s \n"
+	r.Printf(padding, "SUBJECT", "NAMESPACE", "ADMIN", "BACKUP", "RESTORE", "DELETE", "ROLES")
+	selfService := false
+	for _, g := range sorted {
+		r.Printf(padding, g.subject, g.scope, yesNo(g.admin), yesNo(g.capabilities["backup"]), yesNo(g.capabilities["restore"]), yesNo(g.capabilities["delete"]), strings.Join(g.roles, ","))
+		if !g.admin && g.scope != "*" && (g.capabilities["backup"] || g.capabilities["restore"]) {
+			selfService = true
+		}
+	}
+	for _, g := range sorted {
+		if g.admin {
+			continue
+		}
+		if g.wildcard {
+			r.WarningFor(g.subject+"/"+g.scope, "%s gets Kasten rights in namespace %s through a wildcard rule (%s)", g.subject, g.scope, strings.Join(g.roles, ","))
+		}
+		if g.scope == "*" && g.capabilities["restore"] {
+			r.WarningFor(g.subject, "%s is not an admin but can restore in every namespace (%s)", g.subject, strings.Join(g.roles, ","))
+		}
+	}
+	if !selfService {
+		r.Warning("no non admin user can manage the backups or restores of its namespaces, bind k10-basic in their namespaces")
+	}
+	return nil
+}
+
+// roleCapabilities returns the capabilities granted by the rules and whether
+// one of them comes from a wildcard.
+func roleCapabilities(rules []rbacv1.PolicyRule) (map[string]bool, bool) {
+	caps := map[string]bool{}
+	wildcard := false
+	for _, rule := range rules {
+		for _, c := range capabilities {
+			group, groupWildcard := contains(rule.APIGroups, c.group)
+			resource, resourceWildcard := contains(rule.Resources, c.resource)
+			verb, verbWildcard := contains(rule.Verbs, c.verb)
+			if group && resource && verb {
+				caps[c.name] = true
+				wildcard = wildcard || groupWildcard || resourceWildcard || verbWildcard
+			}
+		}
+	}
+	return caps, wildcard
+}
+
+// contains tells if the value is in the list and if it is only through "*".
+func contains(list []string, value string) (bool, bool) {
+	found, wildcard := false, false
+	for _, v := range list {
+		if v == value {
+			return true, false
+		}
+		if v == "*" {
+			found, wildcard = true, true
+		}
+	}
+	return found, wildcard
+}
+
+func subjectName(subject rbacv1.Subject) string {
+	if subject.Kind == rbacv1.ServiceAccountKind {
+		return fmt.Sprintf("%s:%s/%s", subject.Kind, subject.Namespace, subject.Name)
+	}
+	return fmt.Sprintf("%s:%s", subject.Kind, subject.Name)
+}
+
+// ignoredSubject filters out the kubernetes components, they are not users
+// of Kasten.
+func ignoredSubject(subject rbacv1.Subject) bool {
+	if strings.HasPrefix(subject.Name, "system:") {
+		return true
+	}
+	return subject.Kind == rbacv1.ServiceAccountKind && strings.HasPrefix(subject.Namespace, "kube-")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"github.com/michaelcourcy/audit-tool/pkg/snapshot"
+	"k8s.io/client-go/rest"
+)
+
+// collect runs the audit on the cluster and records every response of the
+// api server in a tarball, the secrets redacted, for analyze.
+func collect(path string, kastenNamespace string, kastenRelease string) {
+	config, err := client.Config()
+	if err != nil {
+		panic(err)
+	}
+	s, err := recordAudit(context.Background(), config, report.New(os.Stdout), kastenNamespace, kastenRelease)
+	if err != nil {
+		panic(err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	err = s.Write(f)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("\nThe snapshot of the cluster is written in %s, %d responses recorded \n", path, len(s.Entries))
+	if s.Error != "" {
+		fmt.Fprintf(os.Stderr, "The audit stopped on an error, the snapshot is incomplete: %s \n", s.Error)
+		os.Exit(1)
+	}
+}
+
+// recordAudit runs the audit with config and returns what it read. The
+// snapshot is returned even when a check fails, it holds what was recorded
+// until the failure and the error.
+func recordAudit(ctx context.Context, config *rest.Config, r *report.Report, kastenNamespace string, kastenRelease string) (*snapshot.Snapshot, error) {
+	s := snapshot.Record(config, kastenNamespace, kastenRelease)
+	a, err := newAuditor(config, r, kastenNamespace, kastenRelease)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.run(ctx); err != nil {
+		s.Error = err.Error()
+	}
+	return s, nil
+}
+
+// analyze runs the audit on a tarball written by collect, without access to
+// the cluster.
+func analyze(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	s, err := snapshot.Read(f)
+	if err != nil {
+		panic(err)
+	}
+	now = func() time.Time { return s.Collected }
+	fmt.Printf("Analyzing the snapshot %s collected on %s \n", path, s.Collected.Format("2006-01-02 15:04:05"))
+	if s.Error != "" {
+		fmt.Printf("The collection stopped on an error, the checks after it have no data: %s \n", s.Error)
+	}
+	err = replayAudit(context.Background(), report.New(os.Stdout), s)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// replayAudit runs the audit on the responses of the snapshot.
+func replayAudit(ctx context.Context, r *report.Report, s *snapshot.Snapshot) error {
+	a, err := newAuditor(s.Config(), r, s.KastenNamespace, s.KastenRelease)
+	if err != nil {
+		return err
+	}
+	return a.run(ctx)
+}
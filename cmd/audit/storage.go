@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/michaelcourcy/audit-tool/pkg/client"
+	"github.com/michaelcourcy/audit-tool/pkg/permission"
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"github.com/michaelcourcy/audit-tool/pkg/restorepoint"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+)
+
+const (
+	// defaultPVCUsageThreshold is the percentage of a Kasten PVC above which
+	// it is reported, it can be changed with KASTEN_PVC_USAGE_THRESHOLD.
+	defaultPVCUsageThreshold = 80
+	// catalogClaim is the PVC of the catalog created by the Kasten chart.
+	catalogClaim = "catalog-pv-claim"
+)
+
+// defaultCatalogSizePerRestorePoint is what a restore point is assumed to
+// cost in the catalog, it gives the recommended size on large clusters. Kasten
+// does not publish this figure, 1Mi is an upper estimate of the catalog
+// entries of a restore point, it can be changed with
+// KASTEN_CATALOG_SIZE_PER_RESTORE_POINT.
+const defaultCatalogSizePerRestorePoint = "1Mi"
+
+var kastenStoragePermissions = []permission.Permission{
+	{Resource: "persistentvolumeclaims", Verb: "list", KastenNamespace: true},
+	{Resource: "pods", Verb: "list", KastenNamespace: true},
+	{Group: restorepoint.RestorePointContentResource.Group, Resource: restorepoint.RestorePointContentResource.Resource, Verb: "list"},
+}
+
+// nodeProxyPermissions let the storage check read the usage of the Kasten
+// PVCs from the kubelet stats. nodes/proxy opens the kubelet API of every
+// node, the rbac command only grants it when KASTEN_NODE_PROXY is true.
+var nodeProxyPermissions = []permission.Permission{
+	{Resource: "nodes", Subresource: "proxy", Verb: "get", Optional: true},
+}
+
+// volumeStats is the part of the kubelet stats/summary we need.
+type volumeStats struct {
+	Pods []struct {
+		Volume []struct {
+			PVCRef *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+			UsedBytes     *int64 `json:"usedBytes"`
+			CapacityBytes *int64 `json:"capacityBytes"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+func kastenStorageAudit(ctx context.Context, r *report.Report, corev1Client kubernetes.Interface, metadataClient metadata.Interface, kastenNamespace string) error {
+	r.Section("Kasten storage")
+
+	pvcs, err := corev1Client.CoreV1().PersistentVolumeClaims(kastenNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	if len(pvcs.Items) == 0 {
+		r.Warning("there is no PVC in %s, Kasten runs without persistence", kastenNamespace)
+		return nil
+	}
+
+	usage, err := kastenVolumeUsage(ctx, corev1Client, kastenNamespace)
+	if err != nil {
+		return err
+	}
+	if usage == nil {
+		r.Println("  kubelet volume stats are not reachable through the node proxy, the usage of the PVCs is unknown")
+	}
+
+	restorePoints, err := client.Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return metadataClient.Resource(restorepoint.RestorePointContentResource).List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error { return nil })
+	if err != nil {
+		return err
+	}
+	perRestorePoint := resource.MustParse(defaultCatalogSizePerRestorePoint)
+	if quantity, err := resource.ParseQuantity(os.Getenv("KASTEN_CATALOG_SIZE_PER_RESTORE_POINT")); err == nil {
+		perRestorePoint = quantity
+	}
+	recommended := minCatalogSize.DeepCopy()
+	estimate := resource.NewQuantity(perRestorePoint.Value()*int64(restorePoints), resource.BinarySI)
+	if estimate.Cmp(recommended) > 0 {
+		recommended = *estimate
+	}
+	r.Printf("  %d restore points found, the recommended catalog size is %s \n", restorePoints, recommended.String())
+
+	threshold := getEnvInt("KASTEN_PVC_USAGE_THRESHOLD", defaultPVCUsageThreshold)
+	if threshold <= 0 {
+		// every PVC would be full
+		threshold = defaultPVCUsageThreshold
+	}
+	padding := "  %-30s %-10s %-20s %-10s \n"
+	r.Printf(padding, "PVC", "SIZE", "STORAGECLASS", "USED")
+	sort.Slice(pvcs.Items, func(i, j int) bool { return pvcs.Items[i].Name < pvcs.Items[j].Name })
+	for _, pvc := range pvcs.Items {
+		size := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+		storageClass := "-"
+		if pvc.Spec.StorageClassName != nil {
+			storageClass = *pvc.Spec.StorageClassName
+		}
+		used, percent, known := "-", int64(0), false
+		if stats, ok := usage[pvc.Name]; ok && stats[1] > 0 {
+			percent, known = stats[0]*100/stats[1], true
+			used = fmt.Sprintf("%d%%", percent)
+		}
+		r.Printf(padding, pvc.Name, size.String(), storageClass, used)
+		if known && percent >= int64(threshold) {
+			severity := r.WarningFor
+			if pvc.Name == catalogClaim {
+				severity = r.CriticalFor
+			}
+			severity(pvc.Name, "PVC %s is %d%% full, Kasten stops working when it is full", pvc.Name, percent)
+		}
+		if pvc.Name == catalogClaim && size.Cmp(recommended) < 0 {
+			r.Warning("the catalog PVC is %s, below the %s recommended for %d restore points", size.String(), recommended.String(), restorePoints)
+		}
+	}
+	return nil
+}
+
+// kubeletStats reads the stats/summary of the kubelet of the node through the
+// node proxy.
+var kubeletStats = func(ctx context.Context, corev1Client kubernetes.Interface, node string) ([]byte, error) {
+	return corev1Client.CoreV1().RESTClient().Get().
+		AbsPath("/api/v1/nodes", node, "proxy", "stats", "summary").
+		Do(ctx).
+		Raw()
+}
+
+// kastenVolumeUsage returns the used and capacity bytes of the Kasten PVCs
+// from the kubelet of the nodes running the pods which mount them, or nil if
+// the node proxy is forbidden.
+func kastenVolumeUsage(ctx context.Context, corev1Client kubernetes.Interface, kastenNamespace string) (map[string][2]int64, error) {
+	pods, err := listPods(ctx, corev1Client, kastenNamespace)
+	if err != nil {
+		return nil, err
+	}
+	nodes := map[string]bool{}
+	for _, pod := range pods {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && pod.Spec.NodeName != "" {
+				nodes[pod.Spec.NodeName] = true
+			}
+		}
+	}
+	usage := map[string][2]int64{}
+	for node := range nodes {
+		body, err := kubeletStats(ctx, corev1Client, node)
+		if errors.IsForbidden(err) {
+			return nil, nil
+		}
+		if err != nil {
+			// an unreachable kubelet only hides the usage of its volumes
+			continue
+		}
+		stats := volumeStats{}
+		if err := json.Unmarshal(body, &stats); err != nil {
+			return nil, fmt.Errorf("decoding the stats of node %s: %w", node, err)
+		}
+		for _, pod := range stats.Pods {
+			for _, volume := range pod.Volume {
+				if volume.PVCRef == nil || volume.PVCRef.Namespace != kastenNamespace || volume.UsedBytes == nil || volume.CapacityBytes == nil {
+					continue
+				}
+				usage[volume.PVCRef.Name] = [2]int64{*volume.UsedBytes, *volume.CapacityBytes}
+			}
+		}
+	}
+	return usage, nil
+}
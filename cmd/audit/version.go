@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"github.com/michaelcourcy/audit-tool/pkg/support"
+)
+
+// defaultReleasesBehind is the number of releases behind the latest above
+// which an upgrade is recommended, it can be changed with
+// KASTEN_RELEASES_BEHIND_THRESHOLD.
+const defaultReleasesBehind = 2
+
+// kastenSupportAudit compares the Kasten version with the releases data file,
+// the embedded one or the one given by KASTEN_RELEASES_FILE.
+func kastenSupportAudit(r *report.Report, appVersion string, cluster clusterFacts) error {
+	releases, err := support.Load(os.Getenv("KASTEN_RELEASES_FILE"))
+	if err != nil {
+		return err
+	}
+	release, behind, ok := releases.Find(appVersion)
+	if !ok {
+		if releases.BeforeOldest(appVersion) {
+			r.Critical("kasten %s is older than %s, the oldest release of the releases data file, it is out of support", appVersion, releases[0].Version)
+			return nil
+		}
+		if latest, ok := releases.Latest(); ok {
+			r.Warning("kasten %s is not in the releases data file (latest known %s), its support can't be checked", appVersion, latest.Version)
+		}
+		return nil
+	}
+
+	endOfSupport, err := release.EndOfSupportDate()
+	if err != nil {
+		return err
+	}
+	if now().After(endOfSupport) {
+		r.Critical("kasten %s is out of support since %s", release.Version, release.EndOfSupport)
+	} else {
+		r.Printf("  Kasten %s is supported until %s \n", release.Version, release.EndOfSupport)
+	}
+	if behind > getEnvInt("KASTEN_RELEASES_BEHIND_THRESHOLD", defaultReleasesBehind) {
+		latest, _ := releases.Latest()
+		r.Warning("kasten %s is %d releases behind the latest %s, plan an upgrade", release.Version, behind, latest.Version)
+	} else if behind > 0 {
+		r.Printf("  Kasten %s is %d releases behind the latest \n", release.Version, behind)
+	} else {
+		r.Println("  Kasten is on the latest release")
+	}
+
+	if cluster.openshiftVersion != "" {
+		if !release.OpenShift.Contains(cluster.openshiftVersion) {
+			r.Warning("kasten %s is not tested on OpenShift %s, tested from %s to %s", release.Version, cluster.openshiftVersion, release.OpenShift.Min, release.OpenShift.Max)
+		}
+	} else if cluster.kubernetesVersion != "" {
+		if !release.Kubernetes.Contains(cluster.kubernetesVersion) {
+			r.Warning("kasten %s is not tested on Kubernetes %s, tested from %s to %s", release.Version, cluster.kubernetesVersion, release.Kubernetes.Min, release.Kubernetes.Max)
+		}
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ version="0.0.$number"
 # enter cmd directory to build images 
 cd ../cmd/audit
 GOOS=linux GOARCH=amd64 go build
+go run . rbac > ../../deploy/rbac.yaml
 docker build --platform=linux/amd64 -t $repository/audit-tool:$version-amd64 .
 docker push $repository/audit-tool:$version-amd64
 rm audit
@@ -28,8 +29,8 @@ metadata:
 spec:
   template:
     spec:
-      serviceAccount: k10-k10
-      serviceAccountName: k10-k10
+      serviceAccount: audit-tool
+      serviceAccountName: audit-tool
       containers:
       - name: audit-tool
         image: $repository/audit-tool:$version-amd64
@@ -46,6 +47,7 @@ if ! kubectl delete -n kasten-io -f job.yaml;
 then 
     echo "the audit-tools job was not there"
 fi
+kubectl apply -f rbac.yaml
 kubectl create -n kasten-io -f job.yaml
 sleep 20
 kubectl logs -n kasten-io -f job/audit-tool
\ No newline at end of file
@@ -4,7 +4,6 @@ import (
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 )
 
 type BackupActionSpec struct {
@@ -19,9 +18,12 @@ type BackupActionSubject struct {
 }
 
 type BackupActionStatus struct {
-	EndTime  time.Time `json:"endTime"`
-	Progress int64     `json:"progress"`
-	State    string    `json:"state"`
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+	Progress   int64     `json:"progress"`
+	State      string    `json:"state"`
+	Error      Error     `json:"error"`
+	Exceptions []Error   `json:"exceptions"`
 }
 
 type BackupAction struct {
@@ -39,46 +41,26 @@ type BackupActionList struct {
 	Items []BackupAction `json:"items"`
 }
 
-// DeepCopyInto copies all properties of this object into another object of the
-// same type that is provided as a pointer.
-func (in *BackupAction) DeepCopyInto(out *BackupAction) {
-	out.TypeMeta = in.TypeMeta
-	out.ObjectMeta = in.ObjectMeta
-	out.Status = BackupActionStatus{
-		Progress: in.Status.Progress,
-		EndTime:  in.Status.EndTime,
-		State:    in.Status.State,
+// Start returns when the action was scheduled, or when it started or was
+// created for an action without schedule.
+func (in *BackupAction) Start() time.Time {
+	if !in.Spec.ScheduledTime.IsZero() {
+		return in.Spec.ScheduledTime
 	}
-	out.Spec = BackupActionSpec{
-		ScheduledTime:    in.Spec.ScheduledTime,
-		IgnoreExceptions: in.Spec.IgnoreExceptions,
-		Subject: BackupActionSubject{
-			Name:      in.Spec.Subject.Name,
-			Namespace: in.Spec.Subject.Namespace,
-		},
+	if !in.Status.StartTime.IsZero() {
+		return in.Status.StartTime
 	}
+	return in.CreationTimestamp.Time
 }
 
-// DeepCopyObject returns a generically typed copy of an object
-func (in *BackupAction) DeepCopyObject() runtime.Object {
-	out := BackupAction{}
-	in.DeepCopyInto(&out)
-
-	return &out
-}
-
-// DeepCopyObject returns a generically typed copy of an object
-func (in *BackupActionList) DeepCopyObject() runtime.Object {
-	out := BackupActionList{}
-	out.TypeMeta = in.TypeMeta
-	out.ListMeta = in.ListMeta
-
-	if in.Items != nil {
-		out.Items = make([]BackupAction, len(in.Items))
-		for i := range in.Items {
-			in.Items[i].DeepCopyInto(&out.Items[i])
-		}
+// Duration returns how long the action ran, false while it is not finished.
+func (in *BackupAction) Duration() (time.Duration, bool) {
+	if in.Status.EndTime.IsZero() {
+		return 0, false
 	}
-
-	return &out
+	start := in.Status.StartTime
+	if start.IsZero() {
+		start = in.CreationTimestamp.Time
+	}
+	return in.Status.EndTime.Sub(start), true
 }
@@ -0,0 +1,66 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Error is the error Kasten records in the status of a failed action, the
+// cause is the JSON of the error which caused it, nested the same way.
+type Error struct {
+	Message string  `json:"message"`
+	Cause   string  `json:"cause,omitempty"`
+	Fields  []Field `json:"fields,omitempty"`
+}
+
+// Field is a value Kasten attaches to an error, like the name of the profile
+// or of the blueprint involved.
+type Field struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// UnmarshalJSON accepts an error recorded as a plain message too.
+func (in *Error) UnmarshalJSON(data []byte) error {
+	var message string
+	if json.Unmarshal(data, &message) == nil {
+		*in = Error{Message: message}
+		return nil
+	}
+	type plain Error
+	return json.Unmarshal(data, (*plain)(in))
+}
+
+// Chain returns the error and its causes, the root cause last. A cause which
+// is not JSON is kept as a message.
+func (in Error) Chain() []Error {
+	chain := []Error{}
+	current := in
+	for i := 0; i < 20; i++ {
+		if current.Message != "" || len(current.Fields) > 0 {
+			chain = append(chain, current)
+		}
+		if current.Cause == "" {
+			break
+		}
+		next := Error{}
+		if err := json.Unmarshal([]byte(current.Cause), &next); err != nil {
+			chain = append(chain, Error{Message: current.Cause})
+			break
+		}
+		current = next
+	}
+	return chain
+}
+
+// Field returns the value of the first field with this name along the chain.
+func (in Error) Field(name string) (string, bool) {
+	for _, e := range in.Chain() {
+		for _, field := range e.Fields {
+			if field.Name == name && field.Value != nil {
+				return fmt.Sprint(field.Value), true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,66 @@
+package action
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ExportActionSpec struct {
+	ScheduledTime time.Time           `json:"scheduledTime"`
+	Subject       ExportActionSubject `json:"subject"`
+	Profile       ObjectReference     `json:"profile"`
+	ExportData    ExportData          `json:"exportData"`
+}
+
+// ExportActionSubject is the restore point exported.
+type ExportActionSubject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type ObjectReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type ExportData struct {
+	Enabled bool `json:"enabled"`
+}
+
+type ExportActionStatus struct {
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+	Progress   int64     `json:"progress"`
+	State      string    `json:"state"`
+	Error      Error     `json:"error"`
+	Exceptions []Error   `json:"exceptions"`
+}
+
+type ExportAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ExportActionSpec   `json:"spec"`
+	Status ExportActionStatus `json:"status"`
+}
+
+type ExportActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ExportAction `json:"items"`
+}
+
+// Duration returns how long the export ran, false while it is not finished.
+func (in *ExportAction) Duration() (time.Duration, bool) {
+	if in.Status.EndTime.IsZero() {
+		return 0, false
+	}
+	start := in.Status.StartTime
+	if start.IsZero() {
+		start = in.CreationTimestamp.Time
+	}
+	return in.Status.EndTime.Sub(start), true
+}
@@ -0,0 +1,43 @@
+package action
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type RestoreActionSpec struct {
+	Subject         RestoreActionSubject `json:"subject"`
+	TargetNamespace string               `json:"targetNamespace"`
+}
+
+// RestoreActionSubject is the restore point restored.
+type RestoreActionSubject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type RestoreActionStatus struct {
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+	Progress   int64     `json:"progress"`
+	State      string    `json:"state"`
+	Error      Error     `json:"error"`
+	Exceptions []Error   `json:"exceptions"`
+}
+
+type RestoreAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RestoreActionSpec   `json:"spec"`
+	Status RestoreActionStatus `json:"status"`
+}
+
+type RestoreActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []RestoreAction `json:"items"`
+}
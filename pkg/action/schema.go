@@ -1,8 +1,6 @@
 package action
 
 import (
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -11,17 +9,10 @@ const GroupVersion = "v1alpha1"
 
 var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: GroupVersion}
 
+// The resources of the group, they are read with the dynamic client and
+// converted into the types of this package.
 var (
-	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
-	AddToScheme   = SchemeBuilder.AddToScheme
+	BackupActionResource  = SchemeGroupVersion.WithResource("backupactions")
+	ExportActionResource  = SchemeGroupVersion.WithResource("exportactions")
+	RestoreActionResource = SchemeGroupVersion.WithResource("restoreactions")
 )
-
-func addKnownTypes(scheme *runtime.Scheme) error {
-	scheme.AddKnownTypes(SchemeGroupVersion,
-		&BackupAction{},
-		&BackupActionList{},
-	)
-
-	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
-	return nil
-}
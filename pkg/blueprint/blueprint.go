@@ -0,0 +1,48 @@
+package blueprint
+
+import (
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type BlueprintAction struct {
+	Name               string           `json:"name"`
+	Kind               string           `json:"kind"`
+	ConfigMapNames     []string         `json:"configMapNames"`
+	SecretNames        []string         `json:"secretNames"`
+	InputArtifactNames []string         `json:"inputArtifactNames"`
+	Phases             []BlueprintPhase `json:"phases"`
+	DeferPhase         *BlueprintPhase  `json:"deferPhase,omitempty"`
+}
+
+type BlueprintPhase struct {
+	Func string `json:"func"`
+	Name string `json:"name"`
+}
+
+// Blueprint is a kanister blueprint, unlike the Kasten resources it has no
+// spec, the actions are at the root of the object.
+type Blueprint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Actions map[string]BlueprintAction `json:"actions"`
+}
+
+type BlueprintList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Blueprint `json:"items"`
+}
+
+// ActionNames returns the sorted names of the blueprint actions.
+func (in *Blueprint) ActionNames() []string {
+	var names []string
+	for name := range in.Actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
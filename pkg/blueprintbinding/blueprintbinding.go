@@ -0,0 +1,166 @@
+package blueprintbinding
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	OperatorIn           = "In"
+	OperatorNotIn        = "NotIn"
+	OperatorExists       = "Exists"
+	OperatorDoesNotExist = "DoesNotExist"
+)
+
+type BlueprintBindingSpec struct {
+	BlueprintRef BlueprintRef `json:"blueprintRef"`
+	Resources    Resources    `json:"resources"`
+	Disabled     bool         `json:"disabled"`
+}
+
+type BlueprintRef struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type Resources struct {
+	MatchAll []ResourceRequirement `json:"matchAll"`
+	MatchAny []ResourceRequirement `json:"matchAny"`
+}
+
+// ResourceRequirement holds a single condition of the binding, only one of
+// its members is expected to be set.
+type ResourceRequirement struct {
+	Type        TypeRequirement  `json:"type"`
+	Namespace   ValueRequirement `json:"namespace"`
+	Name        ValueRequirement `json:"name"`
+	Labels      KeyRequirement   `json:"labels"`
+	Annotations KeyRequirement   `json:"annotations"`
+}
+
+type TypeRequirement struct {
+	Operator string         `json:"operator"`
+	Values   []ResourceType `json:"values"`
+}
+
+type ResourceType struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+type ValueRequirement struct {
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+}
+
+type KeyRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values"`
+}
+
+type BlueprintBindingStatus struct {
+	Validation string   `json:"validation"`
+	Error      []string `json:"error"`
+}
+
+type BlueprintBinding struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BlueprintBindingSpec   `json:"spec"`
+	Status BlueprintBindingStatus `json:"status"`
+}
+
+type BlueprintBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []BlueprintBinding `json:"items"`
+}
+
+// Resource describes a kubernetes resource the way a binding sees it.
+type Resource struct {
+	Group       string
+	Resource    string
+	Namespace   string
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// Matches tells if the binding applies its blueprint to the resource.
+// A disabled binding or a binding without any requirement never matches.
+func (in *BlueprintBinding) Matches(r Resource) bool {
+	if in.Spec.Disabled {
+		return false
+	}
+	if len(in.Spec.Resources.MatchAll) == 0 && len(in.Spec.Resources.MatchAny) == 0 {
+		return false
+	}
+	for _, requirement := range in.Spec.Resources.MatchAll {
+		if !requirement.matches(r) {
+			return false
+		}
+	}
+	if len(in.Spec.Resources.MatchAny) == 0 {
+		return true
+	}
+	for _, requirement := range in.Spec.Resources.MatchAny {
+		if requirement.matches(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (in ResourceRequirement) matches(r Resource) bool {
+	switch {
+	case in.Type.Operator != "":
+		found := false
+		for _, t := range in.Type.Values {
+			if t.Group == r.Group && t.Resource == r.Resource {
+				found = true
+			}
+		}
+		return found == (in.Type.Operator == OperatorIn)
+	case in.Namespace.Operator != "":
+		return matchValue(in.Namespace, r.Namespace)
+	case in.Name.Operator != "":
+		return matchValue(in.Name, r.Name)
+	case in.Labels.Operator != "":
+		return matchKey(in.Labels, r.Labels)
+	case in.Annotations.Operator != "":
+		return matchKey(in.Annotations, r.Annotations)
+	}
+	return true
+}
+
+func matchValue(requirement ValueRequirement, value string) bool {
+	found := false
+	for _, v := range requirement.Values {
+		if v == value {
+			found = true
+		}
+	}
+	return found == (requirement.Operator == OperatorIn)
+}
+
+func matchKey(requirement KeyRequirement, values map[string]string) bool {
+	value, ok := values[requirement.Key]
+	switch requirement.Operator {
+	case OperatorExists:
+		return ok
+	case OperatorDoesNotExist:
+		return !ok
+	}
+	found := false
+	if ok {
+		for _, v := range requirement.Values {
+			if v == value {
+				found = true
+			}
+		}
+	}
+	return found == (requirement.Operator == OperatorIn)
+}
@@ -0,0 +1,83 @@
+package blueprintbinding
+
+import "testing"
+
+func TestMatches(t *testing.T) {
+	statefulSets := TypeRequirement{Operator: OperatorIn, Values: []ResourceType{{Group: "apps", Resource: "statefulsets"}}}
+	postgres := Resource{
+		Group:     "apps",
+		Resource:  "statefulsets",
+		Namespace: "app",
+		Name:      "postgres",
+		Labels:    map[string]string{"app.kubernetes.io/name": "postgresql"},
+	}
+	tests := []struct {
+		name    string
+		spec    BlueprintBindingSpec
+		matches bool
+	}{
+		{
+			name: "no requirement",
+		},
+		{
+			name:    "type",
+			spec:    BlueprintBindingSpec{Resources: Resources{MatchAll: []ResourceRequirement{{Type: statefulSets}}}},
+			matches: true,
+		},
+		{
+			name: "disabled",
+			spec: BlueprintBindingSpec{Disabled: true, Resources: Resources{MatchAll: []ResourceRequirement{{Type: statefulSets}}}},
+		},
+		{
+			name: "type and label",
+			spec: BlueprintBindingSpec{Resources: Resources{MatchAll: []ResourceRequirement{
+				{Type: statefulSets},
+				{Labels: KeyRequirement{Key: "app.kubernetes.io/name", Operator: OperatorIn, Values: []string{"postgresql"}}},
+			}}},
+			matches: true,
+		},
+		{
+			name: "type and other label",
+			spec: BlueprintBindingSpec{Resources: Resources{MatchAll: []ResourceRequirement{
+				{Type: statefulSets},
+				{Labels: KeyRequirement{Key: "app.kubernetes.io/name", Operator: OperatorIn, Values: []string{"mysql"}}},
+			}}},
+		},
+		{
+			name: "one of the namespaces",
+			spec: BlueprintBindingSpec{Resources: Resources{MatchAny: []ResourceRequirement{
+				{Namespace: ValueRequirement{Operator: OperatorIn, Values: []string{"other"}}},
+				{Name: ValueRequirement{Operator: OperatorIn, Values: []string{"postgres"}}},
+			}}},
+			matches: true,
+		},
+		{
+			name: "excluded namespace",
+			spec: BlueprintBindingSpec{Resources: Resources{
+				MatchAll: []ResourceRequirement{{Namespace: ValueRequirement{Operator: OperatorNotIn, Values: []string{"app"}}}},
+			}},
+		},
+		{
+			name: "annotation does not exist",
+			spec: BlueprintBindingSpec{Resources: Resources{
+				MatchAll: []ResourceRequirement{{Annotations: KeyRequirement{Key: "kanister.kasten.io/blueprint", Operator: OperatorDoesNotExist}}},
+			}},
+			matches: true,
+		},
+		{
+			name: "label exists but no type matches",
+			spec: BlueprintBindingSpec{Resources: Resources{
+				MatchAll: []ResourceRequirement{{Labels: KeyRequirement{Key: "app.kubernetes.io/name", Operator: OperatorExists}}},
+				MatchAny: []ResourceRequirement{{Type: TypeRequirement{Operator: OperatorIn, Values: []ResourceType{{Group: "apps", Resource: "deployments"}}}}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binding := BlueprintBinding{Spec: tt.spec}
+			if binding.Matches(postgres) != tt.matches {
+				t.Errorf("expected matches %v", tt.matches)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package blueprintbinding
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const GroupName = "config.kio.kasten.io"
+const GroupVersion = "v1alpha1"
+
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: GroupVersion}
+
+// The resources of the group, they are read with the dynamic client and
+// converted into the types of this package.
+var (
+	BlueprintBindingResource = SchemeGroupVersion.WithResource("blueprintbindings")
+)
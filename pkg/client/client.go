@@ -2,16 +2,14 @@ package client
 
 import (
 	"fmt"
+	"sort"
 
-	action "github.com/michaelcourcy/audit-tool/pkg/action"
-	"github.com/michaelcourcy/audit-tool/pkg/profile"
 	helm "github.com/mittwald/go-helm-client"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
 
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
-	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -42,28 +40,6 @@ func Config() (*rest.Config, error) {
 	return config, nil
 }
 
-func ActionClient(config *rest.Config) (*rest.RESTClient, error) {
-	action.AddToScheme(scheme.Scheme)
-	apiConfig := *config
-	apiConfig.ContentConfig.GroupVersion = &schema.GroupVersion{Group: action.GroupName, Version: action.GroupVersion}
-	apiConfig.APIPath = "/apis"
-	apiConfig.NegotiatedSerializer = serializer.NewCodecFactory(scheme.Scheme)
-	apiConfig.UserAgent = rest.DefaultKubernetesUserAgent()
-
-	return rest.UnversionedRESTClientFor(&apiConfig)
-}
-
-func ProfileClient(config *rest.Config) (*rest.RESTClient, error) {
-	profile.AddToScheme(scheme.Scheme)
-	apiConfig := *config
-	apiConfig.ContentConfig.GroupVersion = &schema.GroupVersion{Group: profile.GroupName, Version: profile.GroupVersion}
-	apiConfig.APIPath = "/apis"
-	apiConfig.NegotiatedSerializer = serializer.NewCodecFactory(scheme.Scheme)
-	apiConfig.UserAgent = rest.DefaultKubernetesUserAgent()
-
-	return rest.UnversionedRESTClientFor(&apiConfig)
-}
-
 func HelmClient(config *rest.Config, kastenNamespace string) (helm.Client, error) {
 	opt := &helm.RestConfClientOptions{
 		Options: &helm.Options{
@@ -84,3 +60,36 @@ func HelmClient(config *rest.Config, kastenNamespace string) (helm.Client, error
 func DiscoveryClient(config *rest.Config) (*discovery.DiscoveryClient, error) {
 	return discovery.NewDiscoveryClientForConfig(config)
 }
+
+func MetadataClient(config *rest.Config) (metadata.Interface, error) {
+	return metadata.NewForConfig(config)
+}
+
+func DynamicClient(config *rest.Config) (dynamic.Interface, error) {
+	return dynamic.NewForConfig(config)
+}
+
+// Contexts returns the contexts of the kubeconfig file, the default loading
+// rules are used when kubeconfig is empty.
+func Contexts(kubeconfig string) ([]string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	rawConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).RawConfig()
+	if err != nil {
+		return nil, err
+	}
+	var contexts []string
+	for name := range rawConfig.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, nil
+}
+
+// ContextConfig loads the config of a context of the kubeconfig file.
+func ContextConfig(kubeconfig string, context string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	configOverrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides).ClientConfig()
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// List lists a Kasten resource with the dynamic client, page by page, and
+// converts the result into a typed list like action.BackupActionList, an
+// empty namespace lists all namespaces.
+func List(ctx context.Context, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, namespace string, list interface{}) error {
+	result := unstructured.UnstructuredList{}
+	_, err := Each(ctx, func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		return dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, opts)
+	}, metav1.ListOptions{}, func(object runtime.Object) error {
+		result.Items = append(result.Items, *object.(*unstructured.Unstructured))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(result.UnstructuredContent(), list)
+}
+
+// Get gets a Kasten resource with the dynamic client and converts it into a
+// typed object like profile.Profile.
+func Get(ctx context.Context, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, namespace string, name string, object interface{}) error {
+	result, err := dynamicClient.Resource(gvr).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(result.UnstructuredContent(), object)
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/michaelcourcy/audit-tool/pkg/action"
+	"github.com/michaelcourcy/audit-tool/pkg/blueprint"
+	"github.com/michaelcourcy/audit-tool/pkg/blueprintbinding"
+	"github.com/michaelcourcy/audit-tool/pkg/policy"
+	"github.com/michaelcourcy/audit-tool/pkg/profile"
+	"github.com/michaelcourcy/audit-tool/pkg/restorepoint"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// checkJSONTags walks the struct and fails on any field without a json tag,
+// a field without its tag is silently left empty by the conversion.
+func checkJSONTags(t *testing.T, typ reflect.Type, path string, seen map[reflect.Type]bool) {
+	t.Helper()
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] || !strings.HasPrefix(typ.PkgPath(), "github.com/michaelcourcy/audit-tool/") {
+		return
+	}
+	seen[typ] = true
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, ok := field.Tag.Lookup("json")
+		if !ok || (tag == "" && !field.Anonymous) {
+			t.Errorf("%s.%s has no json tag", path, field.Name)
+			continue
+		}
+		checkJSONTags(t, field.Type, path+"."+field.Name, seen)
+	}
+}
+
+func TestJSONTags(t *testing.T) {
+	for _, object := range []interface{}{
+		action.BackupActionList{},
+		action.ExportActionList{},
+		action.RestoreActionList{},
+		profile.ProfileList{},
+		policy.PolicyList{},
+		blueprint.BlueprintList{},
+		blueprintbinding.BlueprintBindingList{},
+		restorepoint.RestorePointList{},
+		restorepoint.RestorePointContentList{},
+	} {
+		typ := reflect.TypeOf(object)
+		checkJSONTags(t, typ, typ.String(), map[reflect.Type]bool{})
+	}
+}
+
+func TestGet(t *testing.T) {
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"type": "Location",
+			"locationSpec": map[string]interface{}{
+				"infraPortable": true,
+			},
+		},
+	}}
+	object.SetAPIVersion(profile.SchemeGroupVersion.String())
+	object.SetKind("Profile")
+	object.SetNamespace("kasten-io")
+	object.SetName("s3")
+	listKinds := map[schema.GroupVersionResource]string{profile.ProfileResource: "ProfileList"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, object)
+
+	result := profile.Profile{}
+	err := Get(context.TODO(), dynamicClient, profile.ProfileResource, "kasten-io", "s3", &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Spec.LocationSpec.InfraPortable {
+		t.Errorf("expected the profile to be infra portable, got %+v", result.Spec.LocationSpec)
+	}
+
+	list := profile.ProfileList{}
+	err = List(context.TODO(), dynamicClient, profile.ProfileResource, "kasten-io", &list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "s3" {
+		t.Errorf("expected the profile s3, got %+v", list.Items)
+	}
+}
+
+func TestEach(t *testing.T) {
+	pages := map[string]*unstructured.UnstructuredList{}
+	for i, next := range []string{"page-2", "page-3", ""} {
+		page := &unstructured.UnstructuredList{}
+		page.SetContinue(next)
+		for j := 0; j < 2; j++ {
+			page.Items = append(page.Items, unstructured.Unstructured{Object: map[string]interface{}{}})
+		}
+		pages[fmt.Sprintf("page-%d", i+1)] = page
+	}
+
+	var limits []int64
+	scanned, err := Each(context.TODO(), func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+		limits = append(limits, opts.Limit)
+		if opts.Continue == "" {
+			return pages["page-1"], nil
+		}
+		return pages[opts.Continue], nil
+	}, metav1.ListOptions{}, func(object runtime.Object) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanned != 6 {
+		t.Errorf("expected 6 objects scanned, got %d", scanned)
+	}
+	if len(limits) != 3 || limits[0] != PageSize {
+		t.Errorf("expected 3 pages of %d, got the limits %v", PageSize, limits)
+	}
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/pager"
+)
+
+// PageSize is the number of objects asked by request, the lists of a large
+// cluster are read in pages with Limit and Continue so no response holds
+// every object and the API server does not time out.
+const PageSize = 500
+
+// Each reads the list page by page and calls fn on every object, it returns
+// the number of objects scanned.
+func Each(ctx context.Context, list pager.ListPageFunc, opts metav1.ListOptions, fn func(object runtime.Object) error) (int, error) {
+	p := pager.New(list)
+	p.PageSize = PageSize
+	scanned := 0
+	err := p.EachListItem(ctx, opts, func(object runtime.Object) error {
+		scanned++
+		return fn(object)
+	})
+	return scanned, err
+}
@@ -0,0 +1,169 @@
+package history
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// RunLabel holds the name of the run a configmap belongs to.
+	RunLabel = "audit-tool/run"
+	// chunkLabel is the position of the configmap in the run.
+	chunkLabel = "audit-tool/chunk"
+	// ChunkSize keeps every configmap well under the 1MiB limit of an
+	// object in etcd.
+	ChunkSize = 900 * 1024
+	runKey    = "run.json.gz"
+)
+
+// ConfigMapStore keeps every run gzipped in configmaps of the namespace, a
+// run larger than ChunkSize is split across several configmaps.
+type ConfigMapStore struct {
+	Client    kubernetes.Interface
+	Namespace string
+}
+
+func (s ConfigMapStore) Save(ctx context.Context, run Run) (string, error) {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return "", err
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err = writer.Write(data)
+	if err != nil {
+		return "", err
+	}
+	err = writer.Close()
+	if err != nil {
+		return "", err
+	}
+
+	for attempt := 0; attempt < maxNameAttempts; attempt++ {
+		name := attemptName(run, attempt)
+		err = s.create(ctx, name, compressed.Bytes())
+		if errors.IsAlreadyExists(err) {
+			continue
+		}
+		return name, err
+	}
+	return "", fmt.Errorf("%d runs are already kept for %s", maxNameAttempts, Name(run))
+}
+
+// create writes the chunks of the run, the chunks already written are
+// deleted when one can't be created so no partial run is left.
+func (s ConfigMapStore) create(ctx context.Context, name string, content []byte) error {
+	var created []string
+	for chunk := 0; chunk == 0 || len(content) > 0; chunk++ {
+		size := len(content)
+		if size > ChunkSize {
+			size = ChunkSize
+		}
+		configMap := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   fmt.Sprintf("audit-run-%s-%d", configMapName(name), chunk),
+				Labels: map[string]string{RunLabel: name, chunkLabel: strconv.Itoa(chunk)},
+			},
+			BinaryData: map[string][]byte{runKey: content[:size]},
+		}
+		_, err := s.Client.CoreV1().ConfigMaps(s.Namespace).Create(ctx, configMap, metav1.CreateOptions{})
+		if err != nil {
+			for _, written := range created {
+				// the error of the creation is the one reported
+				_ = s.Client.CoreV1().ConfigMaps(s.Namespace).Delete(ctx, written, metav1.DeleteOptions{})
+			}
+			return err
+		}
+		created = append(created, configMap.Name)
+		content = content[size:]
+	}
+	return nil
+}
+
+func (s ConfigMapStore) List(ctx context.Context) ([]string, error) {
+	configMaps, err := s.Client.CoreV1().ConfigMaps(s.Namespace).List(ctx, metav1.ListOptions{LabelSelector: RunLabel})
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, configMap := range configMaps.Items {
+		name := configMap.Labels[RunLabel]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s ConfigMapStore) Load(ctx context.Context, name string) (Run, error) {
+	run := Run{}
+	chunks, err := s.chunks(ctx, name)
+	if err != nil {
+		return run, err
+	}
+	if len(chunks) == 0 {
+		return run, fmt.Errorf("run %s not found in namespace %s", name, s.Namespace)
+	}
+	var content []byte
+	for _, configMap := range chunks {
+		content = append(content, configMap.BinaryData[runKey]...)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return run, err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return run, err
+	}
+	err = json.Unmarshal(data, &run)
+	return run, err
+}
+
+func (s ConfigMapStore) Delete(ctx context.Context, name string) error {
+	chunks, err := s.chunks(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, configMap := range chunks {
+		err = s.Client.CoreV1().ConfigMaps(s.Namespace).Delete(ctx, configMap.Name, metav1.DeleteOptions{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunks returns the configmaps of the run in order.
+func (s ConfigMapStore) chunks(ctx context.Context, name string) ([]v1.ConfigMap, error) {
+	configMaps, err := s.Client.CoreV1().ConfigMaps(s.Namespace).List(ctx, metav1.ListOptions{LabelSelector: RunLabel + "=" + name})
+	if err != nil {
+		return nil, err
+	}
+	chunks := configMaps.Items
+	sort.Slice(chunks, func(i, j int) bool {
+		a, _ := strconv.Atoi(chunks[i].Labels[chunkLabel])
+		b, _ := strconv.Atoi(chunks[j].Labels[chunkLabel])
+		return a < b
+	})
+	return chunks, nil
+}
+
+// configMapName turns the name of the run into a valid object name.
+func configMapName(name string) string {
+	return string(bytes.ToLower([]byte(name)))
+}
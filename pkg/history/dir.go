@@ -0,0 +1,76 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirStore keeps one JSON file per run in a directory, a local one or one
+// mounted from a PVC.
+type DirStore struct {
+	Path string
+}
+
+func (s DirStore) Save(ctx context.Context, run Run) (string, error) {
+	err := os.MkdirAll(s.Path, 0o755)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	for attempt := 0; attempt < maxNameAttempts; attempt++ {
+		name := attemptName(run, attempt)
+		f, err := os.OpenFile(filepath.Join(s.Path, name+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return name, err
+	}
+	return "", fmt.Errorf("%d runs are already kept for %s", maxNameAttempts, Name(run))
+}
+
+func (s DirStore) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s DirStore) Load(ctx context.Context, name string) (Run, error) {
+	run := Run{}
+	data, err := os.ReadFile(filepath.Join(s.Path, name+".json"))
+	if err != nil {
+		return run, err
+	}
+	err = json.Unmarshal(data, &run)
+	return run, err
+}
+
+func (s DirStore) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(s.Path, name+".json"))
+}
@@ -0,0 +1,154 @@
+// Package history keeps the result of every audit run so two runs can be
+// compared, in a local directory or in configmaps of the kasten namespace.
+package history
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+)
+
+// Run is the structured result of one audit.
+type Run struct {
+	Time     time.Time        `json:"time"`
+	Findings []report.Finding `json:"findings"`
+	Summary  report.Summary   `json:"summary"`
+}
+
+// Store saves and loads the runs, the names sort in the order of the runs.
+type Store interface {
+	// Save keeps the run and returns its name, a run started in the same
+	// second as a kept one gets a suffix
+	Save(ctx context.Context, run Run) (string, error)
+	// List returns the names of the runs, the oldest first
+	List(ctx context.Context) ([]string, error)
+	Load(ctx context.Context, name string) (Run, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// maxNameAttempts is the number of runs started in the same second a store
+// can keep.
+const maxNameAttempts = 10
+
+// Name is the name of a run in the stores.
+func Name(run Run) string {
+	return run.Time.UTC().Format("20060102T150405Z")
+}
+
+// attemptName is the name of the run when attempt runs of the same second are
+// already kept, 20260301T120000Z then 20260301T120000Z-2.
+func attemptName(run Run, attempt int) string {
+	if attempt == 0 {
+		return Name(run)
+	}
+	return fmt.Sprintf("%s-%d", Name(run), attempt+1)
+}
+
+// Prune deletes the oldest runs so at most keep runs remain.
+func Prune(ctx context.Context, store Store, keep int) error {
+	names, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	for len(names) > keep {
+		err = store.Delete(ctx, names[0])
+		if err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// NamespaceRPO is the RPO of a namespace in a run.
+type NamespaceRPO struct {
+	// Audited tells if the namespace was audited in the run
+	Audited bool
+	// Protected tells if the namespace had a successful backup, RPO is only
+	// set then
+	Protected bool
+	RPO       time.Duration
+}
+
+// RPOChange is the RPO of a namespace in two runs.
+type RPOChange struct {
+	Namespace     string
+	Before, After NamespaceRPO
+}
+
+// Diff is what changed between two runs.
+type Diff struct {
+	New        []report.Finding
+	Resolved   []report.Finding
+	Persisting []report.Finding
+	RPO        []RPOChange
+}
+
+// Compare returns the findings raised only by the later run, only by the
+// earlier run and by both, and the namespaces whose RPO changed. The findings
+// are matched on their ID, a finding whose message only changed in its counts
+// or ages persists.
+func Compare(from Run, to Run) Diff {
+	before := map[string]bool{}
+	for _, f := range from.Findings {
+		before[f.ID] = true
+	}
+	after := map[string]bool{}
+	diff := Diff{}
+	for _, f := range to.Findings {
+		after[f.ID] = true
+		if before[f.ID] {
+			diff.Persisting = append(diff.Persisting, f)
+		} else {
+			diff.New = append(diff.New, f)
+		}
+	}
+	for _, f := range from.Findings {
+		if !after[f.ID] {
+			diff.Resolved = append(diff.Resolved, f)
+		}
+	}
+
+	namespaces := map[string]bool{}
+	for _, summary := range []report.Summary{from.Summary, to.Summary} {
+		for namespace := range summary.RPO {
+			namespaces[namespace] = true
+		}
+		for namespace := range summary.Unprotected {
+			namespaces[namespace] = true
+		}
+	}
+	for namespace := range namespaces {
+		change := RPOChange{Namespace: namespace, Before: namespaceRPO(from.Summary, namespace), After: namespaceRPO(to.Summary, namespace)}
+		if rpoBucket(change.Before) != rpoBucket(change.After) {
+			diff.RPO = append(diff.RPO, change)
+		}
+	}
+	sort.Slice(diff.RPO, func(i, j int) bool { return diff.RPO[i].Namespace < diff.RPO[j].Namespace })
+	return diff
+}
+
+// namespaceRPO returns the RPO of the namespace in the summary.
+func namespaceRPO(summary report.Summary, namespace string) NamespaceRPO {
+	if summary.Unprotected[namespace] {
+		return NamespaceRPO{Audited: true}
+	}
+	rpo, audited := summary.RPO[namespace]
+	return NamespaceRPO{Audited: audited, Protected: audited, RPO: rpo}
+}
+
+// rpoBucket rounds the RPO to the hour so the time between two runs does not
+// show every namespace as changed, -1 when there is no successful backup and
+// -2 when the namespace was not audited.
+func rpoBucket(rpo NamespaceRPO) int64 {
+	switch {
+	case !rpo.Audited:
+		return -2
+	case !rpo.Protected:
+		return -1
+	}
+	return int64(rpo.RPO.Hours())
+}
@@ -0,0 +1,197 @@
+package history
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/michaelcourcy/audit-tool/pkg/report"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var testTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func finding(message string) report.Finding {
+	return report.Finding{ID: "Namespaces with PVC|" + message, Section: "Namespaces with PVC", Severity: report.Warning, Message: message}
+}
+
+func TestCompare(t *testing.T) {
+	from := Run{
+		Time:     testTime,
+		Findings: []report.Finding{finding("fixed"), finding("still there")},
+		Summary: report.Summary{
+			RPO:         map[string]time.Duration{"app": 2 * time.Hour, "gone": time.Hour, "same": 3*time.Hour + time.Minute},
+			Unprotected: map[string]bool{"db": true, "never": true},
+		},
+	}
+	to := Run{
+		Time:     testTime.Add(time.Hour),
+		Findings: []report.Finding{finding("still there"), finding("appeared")},
+		Summary: report.Summary{
+			RPO:         map[string]time.Duration{"db": time.Hour, "same": 3*time.Hour + 20*time.Minute, "added": time.Hour},
+			Unprotected: map[string]bool{"app": true, "never": true},
+		},
+	}
+	diff := Compare(from, to)
+	if len(diff.New) != 1 || diff.New[0].Message != "appeared" {
+		t.Errorf("unexpected new findings %+v", diff.New)
+	}
+	if len(diff.Resolved) != 1 || diff.Resolved[0].Message != "fixed" {
+		t.Errorf("unexpected resolved findings %+v", diff.Resolved)
+	}
+	if len(diff.Persisting) != 1 || diff.Persisting[0].Message != "still there" {
+		t.Errorf("unexpected persisting findings %+v", diff.Persisting)
+	}
+	var namespaces []string
+	for _, change := range diff.RPO {
+		namespaces = append(namespaces, change.Namespace)
+	}
+	if len(namespaces) != 4 || namespaces[0] != "added" || namespaces[1] != "app" || namespaces[2] != "db" || namespaces[3] != "gone" {
+		t.Errorf("unexpected RPO changes %v", namespaces)
+	}
+}
+
+func TestCompareIDs(t *testing.T) {
+	aged := func(id string, message string) report.Finding {
+		return report.Finding{ID: id, Section: "Disaster recovery", Severity: report.Critical, Message: message}
+	}
+	from := Run{Time: testTime, Findings: []report.Finding{
+		aged("dr|age", "the last successful disaster recovery backup is 2 days and 3 hours old"),
+		aged("dr|profile", "the disaster recovery profile s3 is not valid"),
+	}}
+	to := Run{Time: testTime.Add(time.Hour), Findings: []report.Finding{
+		aged("dr|age", "the last successful disaster recovery backup is 2 days and 4 hours old"),
+	}}
+	diff := Compare(from, to)
+	if len(diff.New) != 0 || len(diff.Persisting) != 1 || diff.Persisting[0].Message != to.Findings[0].Message {
+		t.Errorf("expected the finding whose age changed to persist, got new %+v persisting %+v", diff.New, diff.Persisting)
+	}
+	if len(diff.Resolved) != 1 || diff.Resolved[0].ID != "dr|profile" {
+		t.Errorf("unexpected resolved findings %+v", diff.Resolved)
+	}
+}
+
+// largeRun is a run of random messages, they do not compress and the run
+// needs several configmaps.
+func largeRun() Run {
+	random := rand.New(rand.NewSource(1))
+	large := Run{Time: testTime}
+	for i := 0; i < 3000; i++ {
+		data := make([]byte, 512)
+		random.Read(data)
+		large.Findings = append(large.Findings, finding(hex.EncodeToString(data)))
+	}
+	return large
+}
+
+func TestConfigMapStore(t *testing.T) {
+	corev1Client := fake.NewSimpleClientset()
+	store := ConfigMapStore{Client: corev1Client, Namespace: "kasten-io"}
+
+	large := largeRun()
+	small := Run{Time: testTime.Add(time.Hour), Findings: []report.Finding{finding("small")}}
+	for _, run := range []Run{large, small} {
+		if _, err := store.Save(context.TODO(), run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sameSecond, err := store.Save(context.TODO(), small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sameSecond != Name(small)+"-2" {
+		t.Errorf("expected the second run of the same second to be named %s-2, got %s", Name(small), sameSecond)
+	}
+	if err := store.Delete(context.TODO(), sameSecond); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := store.chunks(context.TODO(), Name(large))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Errorf("expected the large run in several configmaps, got %d", len(chunks))
+	}
+	loaded, err := store.Load(context.TODO(), Name(large))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Findings) != len(large.Findings) || loaded.Findings[2999].Message != large.Findings[2999].Message {
+		t.Errorf("the large run is not read back whole")
+	}
+
+	if err := Prune(context.TODO(), store, 1); err != nil {
+		t.Fatal(err)
+	}
+	names, err := store.List(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != Name(small) {
+		t.Errorf("expected only the last run to be kept, got %v", names)
+	}
+}
+
+func TestConfigMapStoreCleanup(t *testing.T) {
+	corev1Client := fake.NewSimpleClientset()
+	creates := 0
+	corev1Client.PrependReactor("create", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		creates++
+		if creates == 2 {
+			return true, nil, fmt.Errorf("etcd is full")
+		}
+		return false, nil, nil
+	})
+	store := ConfigMapStore{Client: corev1Client, Namespace: "kasten-io"}
+
+	large := largeRun()
+	if _, err := store.Save(context.TODO(), large); err == nil {
+		t.Fatal("expected the save to fail")
+	}
+	names, err := store.List(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("expected the chunks written before the failure to be deleted, got %v", names)
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	store := DirStore{Path: t.TempDir()}
+	run := Run{Time: testTime, Findings: []report.Finding{finding("kept")}, Summary: report.Summary{RPO: map[string]time.Duration{"app": time.Hour}}}
+	if _, err := store.Save(context.TODO(), run); err != nil {
+		t.Fatal(err)
+	}
+	names, err := store.List(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Fatalf("expected one run, got %v", names)
+	}
+	loaded, err := store.Load(context.TODO(), names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Time.Equal(run.Time) || loaded.Findings[0].Message != "kept" || loaded.Summary.RPO["app"] != time.Hour {
+		t.Errorf("unexpected run %+v", loaded)
+	}
+	name, err := store.Save(context.TODO(), run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err = store.List(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != Name(run)+"-2" || len(names) != 2 {
+		t.Errorf("expected the run of the same second to be kept apart, got %s in %v", name, names)
+	}
+}
@@ -0,0 +1,60 @@
+package license
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// SecretName is the secret, or the configmap on older installs, holding the
+// Kasten license in the kasten namespace.
+const SecretName = "k10-license"
+
+// License is the part of the Kasten license the audit reads.
+type License struct {
+	ID           string       `json:"id"`
+	CustomerName string       `json:"customerName"`
+	DateStart    string       `json:"dateStart"`
+	DateEnd      string       `json:"dateEnd"`
+	Product      string       `json:"product"`
+	Restrictions Restrictions `json:"restrictions"`
+}
+
+type Restrictions struct {
+	// Nodes is the licensed node count, 0 means unlimited.
+	Nodes int64 `json:"nodes"`
+}
+
+// Decode reads a license, as YAML or as base64 encoded YAML.
+func Decode(data []byte) (License, error) {
+	license := License{}
+	err := yaml.Unmarshal(data, &license)
+	if err == nil && license.CustomerName != "" {
+		return license, nil
+	}
+	decoded, decodeErr := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if decodeErr != nil {
+		return license, fmt.Errorf("the license is neither yaml nor base64 encoded yaml")
+	}
+	license = License{}
+	if err := yaml.Unmarshal(decoded, &license); err != nil {
+		return license, err
+	}
+	return license, nil
+}
+
+// Expiry parses DateEnd, false when there is no end date.
+func (l License) Expiry() (time.Time, bool, error) {
+	if l.DateEnd == "" {
+		return time.Time{}, false, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, l.DateEnd); err == nil {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("can't parse the end date %q of the license", l.DateEnd)
+}
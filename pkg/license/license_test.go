@@ -0,0 +1,71 @@
+package license
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+const licenseYAML = `id: 0123-abcd
+customerName: Acme
+dateStart: "2025-03-01T00:00:00Z"
+dateEnd: "2026-03-01T00:00:00Z"
+product: K10
+restrictions:
+  nodes: 10
+`
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		customer string
+		nodes    int64
+		fails    bool
+	}{
+		{name: "yaml", data: licenseYAML, customer: "Acme", nodes: 10},
+		{name: "base64 yaml", data: base64.StdEncoding.EncodeToString([]byte(licenseYAML)), customer: "Acme", nodes: 10},
+		{name: "base64 yaml with a newline", data: base64.StdEncoding.EncodeToString([]byte(licenseYAML)) + "\n", customer: "Acme", nodes: 10},
+		{name: "neither yaml nor base64", data: "{not a license", fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			license, err := Decode([]byte(tt.data))
+			if tt.fails {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", license)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if license.CustomerName != tt.customer || license.Restrictions.Nodes != tt.nodes {
+				t.Errorf("expected %s with %d nodes, got %+v", tt.customer, tt.nodes, license)
+			}
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	tests := []struct {
+		dateEnd string
+		expiry  time.Time
+		ok      bool
+		fails   bool
+	}{
+		{dateEnd: "2026-03-01T00:00:00Z", expiry: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), ok: true},
+		{dateEnd: "2026-03-01", expiry: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), ok: true},
+		{dateEnd: ""},
+		{dateEnd: "next year", fails: true},
+	}
+	for _, tt := range tests {
+		expiry, ok, err := License{DateEnd: tt.dateEnd}.Expiry()
+		if (err != nil) != tt.fails {
+			t.Errorf("%q: expected failure %v, got %v", tt.dateEnd, tt.fails, err)
+		}
+		if ok != tt.ok || !expiry.Equal(tt.expiry) {
+			t.Errorf("%q: expected %s %v, got %s %v", tt.dateEnd, tt.expiry, tt.ok, expiry, ok)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package permission
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission is a verb on a resource a check needs.
+type Permission struct {
+	Group    string
+	Resource string
+	// Subresource is the part of the resource the verb applies to, proxy
+	// for nodes/proxy.
+	Subresource string
+	Verb        string
+	// KastenNamespace restricts the permission to the kasten namespace
+	// instead of the whole cluster.
+	KastenNamespace bool
+	// Optional permissions are granted by the manifest but the check still
+	// runs without them, it handles the Forbidden error itself.
+	Optional bool
+}
+
+func (p Permission) String() string {
+	resource := p.rbacResource()
+	if p.Group != "" {
+		resource = resource + "." + p.Group
+	}
+	if p.KastenNamespace {
+		return fmt.Sprintf("%s %s in the kasten namespace", p.Verb, resource)
+	}
+	return fmt.Sprintf("%s %s", p.Verb, resource)
+}
+
+// Missing returns the permissions the current user does not have, it asks the
+// api server with a SelfSubjectAccessReview for each of them. Optional
+// permissions are not checked.
+func Missing(ctx context.Context, corev1Client kubernetes.Interface, kastenNamespace string, permissions []Permission) ([]Permission, error) {
+	var missing []Permission
+	for _, p := range permissions {
+		if p.Optional {
+			continue
+		}
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:       p.Group,
+					Resource:    p.Resource,
+					Subresource: p.Subresource,
+					Verb:        p.Verb,
+				},
+			},
+		}
+		if p.KastenNamespace {
+			review.Spec.ResourceAttributes.Namespace = kastenNamespace
+		}
+		result, err := corev1Client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if !result.Status.Allowed {
+			missing = append(missing, p)
+		}
+	}
+	return missing, nil
+}
+
+// Rules merges the permissions in policy rules, one rule per resource, the
+// cluster wide ones for a ClusterRole and the others for a Role in the kasten
+// namespace.
+func Rules(permissions []Permission) ([]rbacv1.PolicyRule, []rbacv1.PolicyRule) {
+	clusterVerbs := map[[2]string]map[string]bool{}
+	kastenVerbs := map[[2]string]map[string]bool{}
+	for _, p := range permissions {
+		verbs := clusterVerbs
+		if p.KastenNamespace {
+			verbs = kastenVerbs
+		}
+		key := [2]string{p.Group, p.rbacResource()}
+		if verbs[key] == nil {
+			verbs[key] = map[string]bool{}
+		}
+		verbs[key][p.Verb] = true
+	}
+	return policyRules(clusterVerbs), policyRules(kastenVerbs)
+}
+
+// rbacResource is the resource as a policy rule names it, resource/subresource
+// for a subresource.
+func (p Permission) rbacResource() string {
+	if p.Subresource != "" {
+		return p.Resource + "/" + p.Subresource
+	}
+	return p.Resource
+}
+
+func policyRules(verbs map[[2]string]map[string]bool) []rbacv1.PolicyRule {
+	var rules []rbacv1.PolicyRule
+	for key, set := range verbs {
+		rule := rbacv1.PolicyRule{APIGroups: []string{key[0]}, Resources: []string{key[1]}}
+		for verb := range set {
+			rule.Verbs = append(rule.Verbs, verb)
+		}
+		sort.Strings(rule.Verbs)
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].APIGroups[0] != rules[j].APIGroups[0] {
+			return rules[i].APIGroups[0] < rules[j].APIGroups[0]
+		}
+		return rules[i].Resources[0] < rules[j].Resources[0]
+	})
+	return rules
+}
+
+// Join returns a new list with the permissions of every set.
+func Join(sets ...[]Permission) []Permission {
+	var permissions []Permission
+	for _, set := range sets {
+		permissions = append(permissions, set...)
+	}
+	return permissions
+}
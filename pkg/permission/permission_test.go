@@ -0,0 +1,157 @@
+package permission
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeAccess returns a client answering the SelfSubjectAccessReviews with
+// allowed and recording the attributes it was asked.
+func fakeAccess(allowed func(attributes authorizationv1.ResourceAttributes) bool, asked *[]authorizationv1.ResourceAttributes) *fake.Clientset {
+	corev1Client := fake.NewSimpleClientset()
+	corev1Client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := *review.Spec.ResourceAttributes
+		*asked = append(*asked, attributes)
+		review.Status.Allowed = allowed(attributes)
+		return true, review, nil
+	})
+	return corev1Client
+}
+
+func TestMissing(t *testing.T) {
+	permissions := []Permission{
+		{Resource: "nodes", Verb: "list"},
+		{Resource: "secrets", Verb: "get", KastenNamespace: true},
+		{Resource: "nodes", Subresource: "proxy", Verb: "get"},
+		{Group: "route.openshift.io", Resource: "routes", Verb: "list", KastenNamespace: true, Optional: true},
+	}
+	tests := []struct {
+		name    string
+		allowed func(attributes authorizationv1.ResourceAttributes) bool
+		missing []string
+	}{
+		{
+			name:    "everything allowed",
+			allowed: func(authorizationv1.ResourceAttributes) bool { return true },
+		},
+		{
+			name:    "nothing allowed",
+			allowed: func(authorizationv1.ResourceAttributes) bool { return false },
+			missing: []string{"list nodes", "get secrets in the kasten namespace", "get nodes/proxy"},
+		},
+		{
+			name: "secrets only allowed in the kasten namespace",
+			allowed: func(attributes authorizationv1.ResourceAttributes) bool {
+				return attributes.Resource != "secrets" || attributes.Namespace == "kasten-io"
+			},
+		},
+		{
+			name: "secrets allowed in another namespace",
+			allowed: func(attributes authorizationv1.ResourceAttributes) bool {
+				return attributes.Resource != "secrets" || attributes.Namespace == "default"
+			},
+			missing: []string{"get secrets in the kasten namespace"},
+		},
+		{
+			name: "nodes without their proxy",
+			allowed: func(attributes authorizationv1.ResourceAttributes) bool {
+				return attributes.Subresource == ""
+			},
+			missing: []string{"get nodes/proxy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked []authorizationv1.ResourceAttributes
+			missing, err := Missing(context.TODO(), fakeAccess(tt.allowed, &asked), "kasten-io", permissions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, p := range missing {
+				names = append(names, p.String())
+			}
+			if strings.Join(names, ",") != strings.Join(tt.missing, ",") {
+				t.Errorf("expected %q missing, got %q", tt.missing, names)
+			}
+			// the optional permission is never asked
+			if len(asked) != 3 {
+				t.Errorf("expected 3 reviews, got %+v", asked)
+			}
+			for _, attributes := range asked {
+				if attributes.Resource == "routes" {
+					t.Errorf("the optional permission was asked: %+v", attributes)
+				}
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	clusterRules, kastenRules := Rules([]Permission{
+		{Resource: "pods", Verb: "list"},
+		{Resource: "nodes", Verb: "list"},
+		{Resource: "nodes", Subresource: "proxy", Verb: "get"},
+		{Resource: "pods", Verb: "list"},
+		{Resource: "configmaps", Verb: "list", KastenNamespace: true},
+		{Resource: "configmaps", Verb: "get", KastenNamespace: true},
+		{Group: "apps", Resource: "deployments", Verb: "list", Optional: true},
+	})
+	expected := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+		{APIGroups: []string{""}, Resources: []string{"nodes/proxy"}, Verbs: []string{"get"}},
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"list"}},
+	}
+	if rules := rulesString(clusterRules); rules != rulesString(expected) {
+		t.Errorf("unexpected cluster rules %s", rules)
+	}
+	expected = []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list"}},
+	}
+	if rules := rulesString(kastenRules); rules != rulesString(expected) {
+		t.Errorf("unexpected kasten namespace rules %s", rules)
+	}
+}
+
+func rulesString(rules []rbacv1.PolicyRule) string {
+	var result []string
+	for _, rule := range rules {
+		result = append(result, strings.Join(rule.APIGroups, ",")+"|"+strings.Join(rule.Resources, ",")+"|"+strings.Join(rule.Verbs, ","))
+	}
+	return strings.Join(result, " ")
+}
+
+func TestManifest(t *testing.T) {
+	manifest, err := Manifest("audit-tool", "kasten-io", []Permission{
+		{Resource: "nodes", Verb: "list"},
+		{Resource: "secrets", Verb: "get", KastenNamespace: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents := strings.Split(strings.TrimPrefix(string(manifest), "---\n"), "---\n")
+	kinds := []string{"ServiceAccount", "ClusterRole", "ClusterRoleBinding", "Role", "RoleBinding"}
+	if len(documents) != len(kinds) {
+		t.Fatalf("expected %d documents, got %d", len(kinds), len(documents))
+	}
+	for i, kind := range kinds {
+		if !strings.Contains(documents[i], "kind: "+kind+"\n") {
+			t.Errorf("expected document %d to be a %s, got %s", i, kind, documents[i])
+		}
+	}
+	if !strings.Contains(documents[1], "- nodes\n") || strings.Contains(documents[1], "secrets") {
+		t.Errorf("expected the ClusterRole to only grant the nodes, got %s", documents[1])
+	}
+	if !strings.Contains(documents[3], "namespace: kasten-io\n") || !strings.Contains(documents[3], "- secrets\n") {
+		t.Errorf("expected the Role to grant the secrets in kasten-io, got %s", documents[3])
+	}
+}
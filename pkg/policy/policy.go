@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DisasterRecoveryPolicyName is the name Kasten gives to the policy protecting
+// its own catalog.
+const DisasterRecoveryPolicyName = "k10-disaster-recovery-policy"
+
+type PolicySpec struct {
+	Comment                  string                    `json:"comment"`
+	Frequency                string                    `json:"frequency"`
+	Paused                   bool                      `json:"paused"`
+	Retention                map[string]int64          `json:"retention"`
+	Selector                 metav1.LabelSelector      `json:"selector"`
+	Actions                  []PolicyAction            `json:"actions"`
+	KdrSnapshotConfiguration *KdrSnapshotConfiguration `json:"kdrSnapshotConfiguration,omitempty"`
+}
+
+type PolicyAction struct {
+	Action           string           `json:"action"`
+	BackupParameters BackupParameters `json:"backupParameters"`
+	ExportParameters ExportParameters `json:"exportParameters"`
+}
+
+type BackupParameters struct {
+	Profile ObjectReference `json:"profile"`
+}
+
+type ExportParameters struct {
+	Profile    ObjectReference `json:"profile"`
+	ExportData ExportData      `json:"exportData"`
+}
+
+type ExportData struct {
+	Enabled bool `json:"enabled"`
+}
+
+type ObjectReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// KdrSnapshotConfiguration is set on the disaster recovery policy since the
+// quick disaster recovery of Kasten 7.
+type KdrSnapshotConfiguration struct {
+	TakeLocalCatalogSnapshot bool `json:"takeLocalCatalogSnapshot"`
+	ExportCatalogSnapshot    bool `json:"exportCatalogSnapshot"`
+}
+
+type PolicyStatus struct {
+	Validation string `json:"validation"`
+	Hash       int64  `json:"hash"`
+}
+
+type Policy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PolicySpec   `json:"spec"`
+	Status PolicyStatus `json:"status"`
+}
+
+type PolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Policy `json:"items"`
+}
+
+// IsDisasterRecovery tells if the policy protects the Kasten catalog.
+func (in *Policy) IsDisasterRecovery() bool {
+	return in.Name == DisasterRecoveryPolicyName || in.Spec.KdrSnapshotConfiguration != nil
+}
+
+// Profile returns the location profile the policy writes to, the export
+// profile or the backup profile for the disaster recovery policy.
+func (in *Policy) Profile() (ObjectReference, bool) {
+	for _, policyAction := range in.Spec.Actions {
+		if policyAction.Action == "export" && policyAction.ExportParameters.Profile.Name != "" {
+			return policyAction.ExportParameters.Profile, true
+		}
+		if policyAction.Action == "backup" && policyAction.BackupParameters.Profile.Name != "" {
+			return policyAction.BackupParameters.Profile, true
+		}
+	}
+	return ObjectReference{}, false
+}
+
+// Interval returns the time between two runs of the policy, false for on
+// demand policies.
+func (in *Policy) Interval() (time.Duration, bool) {
+	switch in.Spec.Frequency {
+	case "@hourly":
+		return time.Hour, true
+	case "@daily":
+		return 24 * time.Hour, true
+	case "@weekly":
+		return 7 * 24 * time.Hour, true
+	case "@monthly":
+		return 31 * 24 * time.Hour, true
+	case "@yearly":
+		return 366 * 24 * time.Hour, true
+	}
+	return 0, false
+}
@@ -2,7 +2,6 @@ package profile
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 )
 
 type ProfileSpec struct {
@@ -47,7 +46,7 @@ type OpenStack struct {
 type LocationSpec struct {
 	Credential    Credential `json:"credential"`
 	Location      Location   `json:"location"`
-	InfraPortable bool       `jon:"infraPortable"`
+	InfraPortable bool       `json:"infraPortable"`
 }
 
 type Location struct {
@@ -113,110 +112,3 @@ type ProfileList struct {
 
 	Items []Profile `json:"items"`
 }
-
-// DeepCopyInto copies all properties of this object into another object of the
-// same type that is provided as a pointer.
-func (in *Profile) DeepCopyInto(out *Profile) {
-	out.TypeMeta = in.TypeMeta
-	out.ObjectMeta = in.ObjectMeta
-	out.Status = ProfileStatus{
-		Validation: in.Status.Validation,
-		Hash:       in.Status.Hash,
-		Error:      in.Status.Error,
-	}
-	out.Spec = ProfileSpec{
-		Type: in.Spec.Type,
-		LocationSpec: LocationSpec{
-			Credential: Credential{
-				SecretType: in.Spec.LocationSpec.Credential.SecretType,
-				Secret: Secret{
-					ApiVersion: in.Spec.LocationSpec.Credential.Secret.ApiVersion,
-					Kind:       in.Spec.LocationSpec.Credential.Secret.Kind,
-					Name:       in.Spec.LocationSpec.Credential.Secret.Name,
-					Namespace:  in.Spec.LocationSpec.Credential.Secret.Namespace,
-				},
-			},
-			Location: Location{
-				LocationType: in.Spec.LocationSpec.Location.LocationType,
-				ObjectStore: ObjectStore{
-					ObjectStoreType:  in.Spec.LocationSpec.Location.ObjectStore.ObjectStoreType,
-					Endpoint:         in.Spec.LocationSpec.Location.ObjectStore.Endpoint,
-					SkipSSLVerify:    in.Spec.LocationSpec.Location.ObjectStore.SkipSSLVerify,
-					Name:             in.Spec.LocationSpec.Location.ObjectStore.Name,
-					Region:           in.Spec.LocationSpec.Location.ObjectStore.Region,
-					Path:             in.Spec.LocationSpec.Location.ObjectStore.Path,
-					PathType:         in.Spec.LocationSpec.Location.ObjectStore.PathType,
-					ProtectionPeriod: in.Spec.LocationSpec.Location.ObjectStore.ProtectionPeriod,
-				},
-				FileStore: FileStore{
-					ClaimName: in.Spec.LocationSpec.Location.FileStore.ClaimName,
-					Path:      in.Spec.LocationSpec.Location.FileStore.Path,
-				},
-				Vbr: Vbr{
-					ServerAddress: in.Spec.LocationSpec.Location.Vbr.ServerAddress,
-					ServerPort:    in.Spec.LocationSpec.Location.Vbr.ServerPort,
-					RepoName:      in.Spec.LocationSpec.Location.Vbr.RepoName,
-					RepoId:        in.Spec.LocationSpec.Location.Vbr.RepoId,
-					SkipSSLVerify: in.Spec.LocationSpec.Location.Vbr.SkipSSLVerify,
-				},
-			},
-			InfraPortable: in.Spec.LocationSpec.InfraPortable,
-		},
-		Infra: Infra{
-			Type: in.Spec.Infra.Type,
-			OpenStack: OpenStack{
-				KeystoneEndpoint: in.Spec.Infra.OpenStack.KeystoneEndpoint,
-			},
-			Azure: Azure{
-				ADEndpoint:              in.Spec.Infra.Azure.ADEndpoint,
-				ADResource:              in.Spec.Infra.Azure.ADResource,
-				CloudEnv:                in.Spec.Infra.Azure.CloudEnv,
-				CredentialType:          in.Spec.Infra.Azure.CredentialType,
-				ResourceGroup:           in.Spec.Infra.Azure.ResourceGroup,
-				ResourceManagerEndpoint: in.Spec.Infra.Azure.ResourceManagerEndpoint,
-				SubscriptionID:          in.Spec.Infra.Azure.SubscriptionID,
-				UseDefaultMSI:           in.Spec.Infra.Azure.UseDefaultMSI,
-			},
-			Portworx: Portworx{
-				Namespace:   in.Spec.Infra.Portworx.Namespace,
-				ServiceName: in.Spec.Infra.Portworx.ServiceName,
-			},
-			Vsphere: Vsphere{
-				ServerAddress: in.Spec.Infra.Vsphere.ServerAddress,
-			},
-			Credential: Credential{
-				SecretType: in.Spec.Infra.Credential.SecretType,
-				Secret: Secret{
-					ApiVersion: in.Spec.Infra.Credential.Secret.ApiVersion,
-					Kind:       in.Spec.Infra.Credential.Secret.Kind,
-					Name:       in.Spec.Infra.Credential.Secret.Name,
-					Namespace:  in.Spec.Infra.Credential.Secret.Namespace,
-				},
-			},
-		},
-	}
-}
-
-// DeepCopyObject returns a generically typed copy of an object
-func (in *Profile) DeepCopyObject() runtime.Object {
-	out := Profile{}
-	in.DeepCopyInto(&out)
-
-	return &out
-}
-
-// DeepCopyObject returns a generically typed copy of an object
-func (in *ProfileList) DeepCopyObject() runtime.Object {
-	out := ProfileList{}
-	out.TypeMeta = in.TypeMeta
-	out.ListMeta = in.ListMeta
-
-	if in.Items != nil {
-		out.Items = make([]Profile, len(in.Items))
-		for i := range in.Items {
-			in.Items[i].DeepCopyInto(&out.Items[i])
-		}
-	}
-
-	return &out
-}
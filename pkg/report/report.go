@@ -0,0 +1,160 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type Severity string
+
+const (
+	Warning  Severity = "WARNING"
+	Critical Severity = "CRITICAL"
+)
+
+// Finding is a problem raised by a check.
+type Finding struct {
+	// ID identifies the finding from one run to the next, the section, the
+	// format of the message and the subject the finding is about. Unlike the
+	// message it does not hold the counts, ages or durations which change
+	// between runs.
+	ID       string   `json:"id,omitempty"`
+	Section  string   `json:"section"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Summary is what the checks learn about the cluster that the fleet summary
+// compares between clusters.
+type Summary struct {
+	KastenVersion         string        `json:"kastenVersion"`
+	UnprotectedNamespaces int           `json:"unprotectedNamespaces"`
+	WorstRPO              time.Duration `json:"worstRPO"`
+	Immutable             bool          `json:"immutable"`
+	// RPO is the RPO of each namespace audited with a successful backup
+	RPO map[string]time.Duration `json:"rpo,omitempty"`
+	// Unprotected are the namespaces audited without a successful backup
+	Unprotected map[string]bool `json:"unprotected,omitempty"`
+}
+
+// Report prints the audit as it goes and keeps the findings raised by the
+// checks.
+type Report struct {
+	out      io.Writer
+	section  string
+	Findings []Finding
+	Summary  Summary
+}
+
+func New(out io.Writer) *Report {
+	return &Report{out: out}
+}
+
+// Section prints the title of a new part of the audit, findings raised after
+// belong to this section.
+func (r *Report) Section(title string) {
+	r.section = title
+	line := strings.Repeat("=", len(title))
+	fmt.Fprintln(r.out, "")
+	fmt.Fprintln(r.out, line)
+	fmt.Fprintln(r.out, title)
+	fmt.Fprintln(r.out, line)
+}
+
+func (r *Report) Printf(format string, a ...interface{}) {
+	fmt.Fprintf(r.out, format, a...)
+}
+
+func (r *Report) Println(a ...interface{}) {
+	fmt.Fprintln(r.out, a...)
+}
+
+// Warning raises a finding which is about the whole section, a check raising
+// it several times, for different namespaces or resources, uses WarningFor.
+func (r *Report) Warning(format string, a ...interface{}) {
+	r.add(Warning, "", format, a...)
+}
+
+// WarningFor raises a finding about subject, a namespace or a resource.
+func (r *Report) WarningFor(subject string, format string, a ...interface{}) {
+	r.add(Warning, subject, format, a...)
+}
+
+func (r *Report) Critical(format string, a ...interface{}) {
+	r.add(Critical, "", format, a...)
+}
+
+func (r *Report) CriticalFor(subject string, format string, a ...interface{}) {
+	r.add(Critical, subject, format, a...)
+}
+
+func (r *Report) add(severity Severity, subject string, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	id := r.section + "|" + format
+	if subject != "" {
+		id += "|" + subject
+	}
+	r.Findings = append(r.Findings, Finding{ID: id, Section: r.section, Severity: severity, Message: message})
+	fmt.Fprintf(r.out, "  --> %s !! %s \n", severity, message)
+}
+
+// Count returns the number of findings with this severity.
+func (r *Report) Count(severity Severity) int {
+	count := 0
+	for _, finding := range r.Findings {
+		if finding.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// Buffer returns a report of the current section printing in memory, the
+// checks running in parallel fill one each and Append them in a stable order.
+func (r *Report) Buffer() *Report {
+	return &Report{out: &bytes.Buffer{}, section: r.section}
+}
+
+// Append prints what the buffered report printed and takes its findings and
+// summary.
+func (r *Report) Append(buffered *Report) {
+	if out, ok := buffered.out.(*bytes.Buffer); ok {
+		r.out.Write(out.Bytes())
+	}
+	r.Findings = append(r.Findings, buffered.Findings...)
+	if buffered.Summary.KastenVersion != "" {
+		r.Summary.KastenVersion = buffered.Summary.KastenVersion
+	}
+	r.Summary.UnprotectedNamespaces += buffered.Summary.UnprotectedNamespaces
+	if buffered.Summary.WorstRPO > r.Summary.WorstRPO {
+		r.Summary.WorstRPO = buffered.Summary.WorstRPO
+	}
+	r.Summary.Immutable = r.Summary.Immutable || buffered.Summary.Immutable
+	for namespace, rpo := range buffered.Summary.RPO {
+		r.Summary.SetRPO(namespace, rpo)
+	}
+	for namespace := range buffered.Summary.Unprotected {
+		r.Summary.SetUnprotected(namespace)
+	}
+}
+
+// SetRPO records the RPO of a namespace with a successful backup.
+func (s *Summary) SetRPO(namespace string, rpo time.Duration) {
+	if s.RPO == nil {
+		s.RPO = map[string]time.Duration{}
+	}
+	s.RPO[namespace] = rpo
+	delete(s.Unprotected, namespace)
+}
+
+// SetUnprotected records a namespace without a successful backup.
+func (s *Summary) SetUnprotected(namespace string) {
+	if s.Unprotected == nil {
+		s.Unprotected = map[string]bool{}
+	}
+	s.Unprotected[namespace] = true
+	delete(s.RPO, namespace)
+}
@@ -0,0 +1,52 @@
+package restorepoint
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type RestorePointSpec struct {
+	RestorePointContentRef ObjectReference `json:"restorePointContentRef"`
+}
+
+type ObjectReference struct {
+	Name string `json:"name"`
+}
+
+type RestorePointStatus struct {
+	ActionTime   time.Time `json:"actionTime"`
+	LogicalSize  int64     `json:"logicalSize"`
+	PhysicalSize int64     `json:"physicalSize"`
+}
+
+type RestorePoint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RestorePointSpec   `json:"spec"`
+	Status RestorePointStatus `json:"status"`
+}
+
+type RestorePointList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []RestorePoint `json:"items"`
+}
+
+// RestorePointContent is the cluster scoped content of the restore points,
+// it outlives the namespace of the application.
+type RestorePointContent struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status RestorePointStatus `json:"status"`
+}
+
+type RestorePointContentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []RestorePointContent `json:"items"`
+}
@@ -0,0 +1,253 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/michaelcourcy/audit-tool/pkg/license"
+	"sigs.k8s.io/yaml"
+)
+
+// redacted replaces the secret values in the snapshot.
+const redacted = "REDACTED"
+
+// helmReleaseType is the type of the secrets where helm stores its releases.
+const helmReleaseType = "helm.sh/release.v1"
+
+// readValues are the helm values the checks read, every other string of the
+// values is redacted. A value is kept when its dotted path is one of them or
+// is below one of them.
+var readValues = []string{
+	"auth.basicAuth.enabled",
+	"auth.ldap.enabled",
+	"auth.oidcAuth.enabled",
+	"auth.openshift.enabled",
+	"auth.tokenAuth.enabled",
+	"cacertconfigmap.name",
+	"encryption.primaryKey.awsCmkKeyId",
+	"encryption.primaryKey.vaultTransitKeyName",
+	"externalGateway.create",
+	"fips.enabled",
+	"garbagecollector",
+	"global.persistence.catalog.size",
+	"global.persistence.enabled",
+	"global.persistence.size",
+	"global.persistence.storageClass",
+	"grafana.enabled",
+	"ingress.create",
+	"ingress.tls.enabled",
+	"injectKanisterSidecar.enabled",
+	"injectKanisterSidecar.namespaceSelector.matchLabels",
+	"limiter",
+	"prometheus.server.enabled",
+	"route.enabled",
+	"route.tls.enabled",
+}
+
+// redact removes the secret values from a response. The license is reduced
+// to the fields of the licensing check and the helm releases keep their
+// values, redacted, for the helm checks.
+func redact(path string, body []byte) ([]byte, error) {
+	if strings.HasSuffix(path, "/configmaps/"+license.SecretName) {
+		return redactLicenseConfigMap(body)
+	}
+	if !strings.Contains(path, "/secrets") {
+		return body, nil
+	}
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(body, &object); err != nil {
+		// not a json object, a Status or a protobuf answer has no value to hide
+		return body, nil
+	}
+	secrets := []interface{}{object}
+	if items, ok := object["items"].([]interface{}); ok {
+		secrets = items
+	}
+	for _, s := range secrets {
+		secret, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		err := redactSecret(secret)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(object)
+}
+
+// redactSecret redacts the values of a secret. The annotations, where
+// kubectl keeps the last applied configuration, and the managed fields are
+// dropped, no check reads them.
+func redactSecret(secret map[string]interface{}) error {
+	metadata, _ := secret["metadata"].(map[string]interface{})
+	delete(metadata, "annotations")
+	delete(metadata, "managedFields")
+	if stringData, ok := secret["stringData"].(map[string]interface{}); ok {
+		for key := range stringData {
+			stringData[key] = redacted
+		}
+	}
+	data, ok := secret["data"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if metadata["name"] == license.SecretName {
+		for key, value := range data {
+			encoded, _ := value.(string)
+			data[key] = base64.StdEncoding.EncodeToString([]byte(redacted))
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if key != "license" || err != nil {
+				continue
+			}
+			if reduced, ok := reduceLicense(decoded); ok {
+				data[key] = base64.StdEncoding.EncodeToString(reduced)
+			}
+		}
+		return nil
+	}
+	if secret["type"] == helmReleaseType {
+		encoded, _ := data["release"].(string)
+		release, err := redactRelease(encoded)
+		if err != nil {
+			return err
+		}
+		data["release"] = release
+		return nil
+	}
+	for key := range data {
+		data[key] = base64.StdEncoding.EncodeToString([]byte(redacted))
+	}
+	return nil
+}
+
+// redactRelease decodes a helm release, base64 encoded by kubernetes then by
+// helm and gzipped, redacts the sensitive values and drops the manifests of
+// the release and of its hooks which hold the rendered secrets.
+func redactRelease(encoded string) (string, error) {
+	helmEncoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	compressed, err := base64.StdEncoding.DecodeString(string(helmEncoded))
+	if err != nil {
+		return "", err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", err
+	}
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		return "", err
+	}
+	release := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &release); err != nil {
+		return "", err
+	}
+	if config, ok := release["config"].(map[string]interface{}); ok {
+		redactValues("", config)
+	}
+	release["manifest"] = ""
+	hooks, _ := release["hooks"].([]interface{})
+	for _, h := range hooks {
+		if hook, ok := h.(map[string]interface{}); ok {
+			hook["manifest"] = ""
+		}
+	}
+
+	raw, err = json.Marshal(release)
+	if err != nil {
+		return "", err
+	}
+	var buffer bytes.Buffer
+	gzw := gzip.NewWriter(&buffer)
+	if _, err := gzw.Write(raw); err != nil {
+		return "", err
+	}
+	if err := gzw.Close(); err != nil {
+		return "", err
+	}
+	helmEncoded = []byte(base64.StdEncoding.EncodeToString(buffer.Bytes()))
+	return base64.StdEncoding.EncodeToString(helmEncoded), nil
+}
+
+// redactValues replaces the strings of the values the checks don't read,
+// they stay set for the checks testing their presence.
+func redactValues(prefix string, values map[string]interface{}) {
+	for name, value := range values {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		values[name] = redactValue(path, value)
+	}
+}
+
+func redactValue(path string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redactValues(path, v)
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(path, v[i])
+		}
+	case string:
+		if v != "" && !isReadValue(path) {
+			return redacted
+		}
+	}
+	return value
+}
+
+func isReadValue(path string) bool {
+	for _, read := range readValues {
+		if path == read || strings.HasPrefix(path, read+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// reduceLicense keeps the fields of the license read by the licensing check,
+// the signature and the rest of the license are dropped. False when the
+// license can't be decoded.
+func reduceLicense(data []byte) ([]byte, bool) {
+	l, err := license.Decode(data)
+	if err != nil {
+		return nil, false
+	}
+	raw, err := yaml.Marshal(l)
+	if err != nil {
+		return nil, false
+	}
+	return []byte(base64.StdEncoding.EncodeToString(raw)), true
+}
+
+// redactLicenseConfigMap reduces the license of the configmap older installs
+// use.
+func redactLicenseConfigMap(body []byte) ([]byte, error) {
+	configMap := map[string]interface{}{}
+	if err := json.Unmarshal(body, &configMap); err != nil {
+		return body, nil
+	}
+	data, ok := configMap["data"].(map[string]interface{})
+	if !ok {
+		return body, nil
+	}
+	for key, value := range data {
+		encoded, _ := value.(string)
+		data[key] = redacted
+		if key != "license" {
+			continue
+		}
+		if reduced, ok := reduceLicense([]byte(encoded)); ok {
+			data[key] = string(reduced)
+		}
+	}
+	return json.Marshal(configMap)
+}
@@ -0,0 +1,196 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/michaelcourcy/audit-tool/pkg/license"
+)
+
+// encodeRelease encodes a release the way helm and kubernetes store it in
+// the data of a secret.
+func encodeRelease(t *testing.T, release map[string]interface{}) string {
+	raw, err := json.Marshal(release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	gzw := gzip.NewWriter(&buffer)
+	gzw.Write(raw)
+	gzw.Close()
+	helmEncoded := base64.StdEncoding.EncodeToString(buffer.Bytes())
+	return base64.StdEncoding.EncodeToString([]byte(helmEncoded))
+}
+
+func decodeRelease(t *testing.T, encoded string) string {
+	helmEncoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := base64.StdEncoding.DecodeString(string(helmEncoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+// releaseFixture is a release whose values hold credentials under names
+// which don't look sensitive.
+func releaseFixture(t *testing.T) string {
+	return encodeRelease(t, map[string]interface{}{
+		"name":     "k10",
+		"manifest": "kind: Secret\ndata:\n  password: cmVuZGVyZWQ=\n",
+		"hooks": []interface{}{
+			map[string]interface{}{"name": "k10-hook", "manifest": "kind: Secret\ndata:\n  token: aG9vay10b2tlbg==\n"},
+		},
+		"config": map[string]interface{}{
+			"auth": map[string]interface{}{
+				"basicAuth": map[string]interface{}{"enabled": true, "htpasswd": "admin:$apr1$hashed"},
+				"ldap":      map[string]interface{}{"enabled": "true", "bindPW": "ldap-bind-pw"},
+			},
+			"secrets": map[string]interface{}{"dockerConfig": "docker-config-json"},
+			"extraCredentials": []interface{}{
+				"listed-credential",
+				map[string]interface{}{"user": "svc", "pass": "nested-credential"},
+			},
+			"global":           map[string]interface{}{"persistence": map[string]interface{}{"size": "20Gi"}},
+			"garbagecollector": map[string]interface{}{"keepMaxActions": "1000"},
+		},
+	})
+}
+
+// leaked are the credentials of releaseFixture.
+var leaked = []string{"admin:$apr1$hashed", "ldap-bind-pw", "docker-config-json", "listed-credential", "nested-credential", "cmVuZGVyZWQ=", "aG9vay10b2tlbg=="}
+
+func TestRedactRelease(t *testing.T) {
+	redactedRelease, err := redactRelease(releaseFixture(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := decodeRelease(t, redactedRelease)
+	for _, secret := range leaked {
+		if strings.Contains(raw, secret) {
+			t.Errorf("%s survived the redaction: %s", secret, raw)
+		}
+	}
+	for _, read := range []string{`"size":"20Gi"`, `"keepMaxActions":"1000"`, `"enabled":"true"`, `"enabled":true`} {
+		if !strings.Contains(raw, read) {
+			t.Errorf("%s, read by the checks, was redacted: %s", read, raw)
+		}
+	}
+}
+
+func TestRedactSecret(t *testing.T) {
+	licenseYAML := "id: license-1\ncustomerName: acme\ndateEnd: \"2027-01-01\"\nrestrictions:\n  nodes: 10\nsignature: license-signature\n"
+	tests := []struct {
+		name   string
+		secret map[string]interface{}
+		hidden []string
+		kept   []string
+	}{
+		{
+			name: "helm release",
+			secret: map[string]interface{}{
+				"type":     helmReleaseType,
+				"metadata": map[string]interface{}{"name": "sh.helm.release.v1.k10.v1"},
+				"data":     map[string]interface{}{"release": releaseFixture(t)},
+			},
+			hidden: leaked,
+		},
+		{
+			name: "opaque secret",
+			secret: map[string]interface{}{
+				"type":     "Opaque",
+				"metadata": map[string]interface{}{"name": "k10-dr-secret"},
+				"data":     map[string]interface{}{"key": base64.StdEncoding.EncodeToString([]byte("dr-passphrase"))},
+			},
+			hidden: []string{"dr-passphrase"},
+		},
+		{
+			name: "applied with kubectl",
+			secret: map[string]interface{}{
+				"type": "Opaque",
+				"metadata": map[string]interface{}{
+					"name": "k10-s3-secret",
+					"annotations": map[string]interface{}{
+						"kubectl.kubernetes.io/last-applied-configuration": `{"apiVersion":"v1","kind":"Secret","stringData":{"aws_secret_access_key":"applied-credential"}}`,
+					},
+					"managedFields": []interface{}{
+						map[string]interface{}{"manager": "kubectl", "fieldsV1": map[string]interface{}{"f:stringData": map[string]interface{}{"f:managed-credential": map[string]interface{}{}}}},
+					},
+				},
+				"stringData": map[string]interface{}{"aws_access_key_id": "string-credential"},
+				"data":       map[string]interface{}{"aws_secret_access_key": base64.StdEncoding.EncodeToString([]byte("applied-credential"))},
+			},
+			hidden: []string{"applied-credential", "managed-credential", "string-credential"},
+		},
+		{
+			name: "license",
+			secret: map[string]interface{}{
+				"type":     "Opaque",
+				"metadata": map[string]interface{}{"name": license.SecretName},
+				"data": map[string]interface{}{
+					"license": base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString([]byte(licenseYAML)))),
+					"other":   base64.StdEncoding.EncodeToString([]byte("other-value")),
+				},
+			},
+			hidden: []string{"license-signature", "other-value"},
+			kept:   []string{"acme", "2027-01-01"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := redactSecret(test.secret); err != nil {
+				t.Fatal(err)
+			}
+			data := test.secret["data"].(map[string]interface{})
+			var decoded []string
+			for key, value := range data {
+				raw, err := base64.StdEncoding.DecodeString(value.(string))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if key == "release" {
+					decoded = append(decoded, decodeRelease(t, value.(string)))
+					continue
+				}
+				// the license is base64 encoded yaml
+				if inner, err := base64.StdEncoding.DecodeString(string(raw)); err == nil {
+					raw = inner
+				}
+				decoded = append(decoded, string(raw))
+			}
+			// the rest of the secret, its metadata and string data
+			delete(test.secret, "data")
+			raw, err := json.Marshal(test.secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded = append(decoded, string(raw))
+			all := strings.Join(decoded, "\n")
+			for _, secret := range test.hidden {
+				if strings.Contains(all, secret) {
+					t.Errorf("%s survived the redaction: %s", secret, all)
+				}
+			}
+			for _, read := range test.kept {
+				if !strings.Contains(all, read) {
+					t.Errorf("%s, read by the checks, was redacted: %s", read, all)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// indexFile is the entry of the tarball describing the recorded responses.
+const indexFile = "index.json"
+
+// Entry is a response of the api server recorded during the collection.
+type Entry struct {
+	Method string `json:"method"`
+	// URL is the path and the query of the request.
+	URL string `json:"url"`
+	// BodyHash identifies the body of the requests which are not a GET, the
+	// SelfSubjectAccessReviews.
+	BodyHash    string `json:"bodyHash,omitempty"`
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	File        string `json:"file"`
+}
+
+// Snapshot is what the checks read from a cluster, it is recorded by
+// `audit collect` and replayed by `audit analyze` without cluster access.
+type Snapshot struct {
+	Collected       time.Time `json:"collected"`
+	KastenNamespace string    `json:"kastenNamespace"`
+	KastenRelease   string    `json:"kastenRelease"`
+	// Error is the error which stopped the audit during the collection, the
+	// snapshot then holds the responses recorded until it.
+	Error   string  `json:"error,omitempty"`
+	Entries []Entry `json:"entries"`
+
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// Record wraps the transport of config, every request made with it is then
+// recorded in the returned snapshot, the secrets redacted.
+func Record(config *rest.Config, kastenNamespace string, kastenRelease string) *Snapshot {
+	s := &Snapshot{
+		Collected:       time.Now(),
+		KastenNamespace: kastenNamespace,
+		KastenRelease:   kastenRelease,
+		files:           map[string][]byte{},
+	}
+	config.Wrap(func(next http.RoundTripper) http.RoundTripper {
+		return &recorder{snapshot: s, next: next}
+	})
+	return s
+}
+
+// Config returns a config whose requests are answered from the snapshot.
+func (s *Snapshot) Config() *rest.Config {
+	return &rest.Config{Host: "http://snapshot", Transport: &replayer{snapshot: s}}
+}
+
+// Write writes the snapshot as a gzipped tarball.
+func (s *Snapshot) Write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	index, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(tw, indexFile, index, s.Collected); err != nil {
+		return err
+	}
+	for _, entry := range s.Entries {
+		if err := writeFile(tw, entry.File, s.files[entry.File], s.Collected); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Read reads a snapshot written by Write.
+func Read(r io.Reader) (*Snapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[header.Name] = data
+	}
+	index, ok := files[indexFile]
+	if !ok {
+		return nil, fmt.Errorf("%s is missing, this is not a snapshot of the audit tool", indexFile)
+	}
+	s := &Snapshot{}
+	if err := json.Unmarshal(index, s); err != nil {
+		return nil, err
+	}
+	s.files = files
+	return s, nil
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: modTime})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+func (s *Snapshot) add(entry Entry, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.File = fmt.Sprintf("responses/%05d.json", len(s.Entries))
+	s.Entries = append(s.Entries, entry)
+	s.files[entry.File] = body
+}
+
+func (s *Snapshot) find(method string, url string, bodyHash string) (Entry, []byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.Entries {
+		if entry.Method == method && entry.URL == url && entry.BodyHash == bodyHash {
+			return entry, s.files[entry.File], true
+		}
+	}
+	return Entry{}, nil, false
+}
+
+// requestKey returns the url and the hash of the body identifying a request.
+func requestKey(req *http.Request) (string, string, error) {
+	url := req.URL.Path
+	if req.URL.RawQuery != "" {
+		url += "?" + req.URL.RawQuery
+	}
+	if req.Method == http.MethodGet || req.Body == nil {
+		return url, "", nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	return url, hex.EncodeToString(sum[:]), nil
+}
+
+type recorder struct {
+	snapshot *Snapshot
+	next     http.RoundTripper
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	url, bodyHash, err := requestKey(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	recorded, err := redact(req.URL.Path, body)
+	if err != nil {
+		return nil, fmt.Errorf("redacting %s: %w", url, err)
+	}
+	r.snapshot.add(Entry{
+		Method:      req.Method,
+		URL:         url,
+		BodyHash:    bodyHash,
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}, recorded)
+	return resp, nil
+}
+
+type replayer struct {
+	snapshot *Snapshot
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	url, bodyHash, err := requestKey(req)
+	if err != nil {
+		return nil, err
+	}
+	entry, body, ok := r.snapshot.find(req.Method, url, bodyHash)
+	if !ok {
+		body = []byte(fmt.Sprintf(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404,"message":"%s %s is not in the snapshot"}`, req.Method, url))
+		entry = Entry{Status: http.StatusNotFound, ContentType: "application/json"}
+	}
+	return &http.Response{
+		StatusCode:    entry.Status,
+		Status:        http.StatusText(entry.Status),
+		Header:        http.Header{"Content-Type": []string{entry.ContentType}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
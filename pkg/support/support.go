@@ -0,0 +1,120 @@
+package support
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed releases.yaml
+var embeddedReleases []byte
+
+// Range is an inclusive range of minor versions, "1.28" to "1.31".
+type Range struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+// Contains tells if the minor version of version is in the range, false when
+// one of them can't be parsed.
+func (r Range) Contains(version string) bool {
+	v, err := minor(version)
+	if err != nil {
+		return false
+	}
+	min, err := minor(r.Min)
+	if err != nil {
+		return false
+	}
+	max, err := minor(r.Max)
+	if err != nil {
+		return false
+	}
+	return !v.LessThan(min) && !v.GreaterThan(max)
+}
+
+// Release is a minor release of Kasten, its patches share its support.
+type Release struct {
+	Version      string `json:"version"`
+	EndOfSupport string `json:"endOfSupport"`
+	Kubernetes   Range  `json:"kubernetes"`
+	OpenShift    Range  `json:"openshift"`
+}
+
+// EndOfSupportDate parses EndOfSupport, formatted as 2006-01-02.
+func (r Release) EndOfSupportDate() (time.Time, error) {
+	return time.Parse("2006-01-02", r.EndOfSupport)
+}
+
+// Releases are sorted from the oldest to the newest.
+type Releases []Release
+
+// Load reads the releases from the file at path or the embedded file when
+// path is empty.
+func Load(path string) (Releases, error) {
+	data := embeddedReleases
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	releases := Releases{}
+	if err := yaml.Unmarshal(data, &releases); err != nil {
+		return nil, fmt.Errorf("decoding the kasten releases: %w", err)
+	}
+	return releases, nil
+}
+
+// Find returns the release of version and how many releases are newer, false
+// when the release is unknown.
+func (releases Releases) Find(version string) (Release, int, bool) {
+	v, err := minor(version)
+	if err != nil {
+		return Release{}, 0, false
+	}
+	for i, release := range releases {
+		candidate, err := minor(release.Version)
+		if err == nil && candidate.Equal(v) {
+			return release, len(releases) - 1 - i, true
+		}
+	}
+	return Release{}, 0, false
+}
+
+// BeforeOldest tells if version is older than the oldest release, such a
+// version is out of support.
+func (releases Releases) BeforeOldest(version string) bool {
+	if len(releases) == 0 {
+		return false
+	}
+	v, err := minor(version)
+	if err != nil {
+		return false
+	}
+	oldest, err := minor(releases[0].Version)
+	return err == nil && v.LessThan(oldest)
+}
+
+// Latest returns the newest release.
+func (releases Releases) Latest() (Release, bool) {
+	if len(releases) == 0 {
+		return Release{}, false
+	}
+	return releases[len(releases)-1], true
+}
+
+// minor drops the patch and pre-release of a version, "v1.29.3-gke.1" gives
+// 1.29.0.
+func minor(version string) (*semver.Version, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	return semver.New(v.Major(), v.Minor(), 0, "", ""), nil
+}
@@ -0,0 +1,98 @@
+package support
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+func TestRangeContains(t *testing.T) {
+	r := Range{Min: "1.28", Max: "1.31"}
+	tests := map[string]bool{
+		"1.28":          true,
+		"v1.29.3-gke.1": true,
+		"1.31.12":       true,
+		"1.27.9":        false,
+		"1.32.0":        false,
+		"not a version": false,
+	}
+	for version, expected := range tests {
+		if r.Contains(version) != expected {
+			t.Errorf("expected %s in %s-%s to be %v", version, r.Min, r.Max, expected)
+		}
+	}
+	if (Range{Min: "1.28"}).Contains("1.29") {
+		t.Error("expected a range without max to contain nothing")
+	}
+}
+
+func TestFind(t *testing.T) {
+	releases := Releases{{Version: "7.5"}, {Version: "8.0"}, {Version: "8.5"}}
+	tests := []struct {
+		version string
+		found   bool
+		release string
+		newer   int
+	}{
+		{version: "7.5.10", found: true, release: "7.5", newer: 2},
+		{version: "8.0.0", found: true, release: "8.0", newer: 1},
+		{version: "v8.5.3", found: true, release: "8.5", newer: 0},
+		{version: "6.0.12"},
+		{version: "latest"},
+	}
+	for _, tt := range tests {
+		release, newer, found := releases.Find(tt.version)
+		if found != tt.found || release.Version != tt.release || newer != tt.newer {
+			t.Errorf("expected %s to find %q with %d newer, got %q with %d newer (found %v)", tt.version, tt.release, tt.newer, release.Version, newer, found)
+		}
+	}
+}
+
+func TestBeforeOldest(t *testing.T) {
+	releases := Releases{{Version: "6.0"}, {Version: "8.5"}}
+	tests := map[string]bool{
+		"5.5.8":  true,
+		"v4.0.0": true,
+		"6.0.0":  false,
+		"7.0.1":  false,
+		"9.0.0":  false,
+		"latest": false,
+	}
+	for version, expected := range tests {
+		if releases.BeforeOldest(version) != expected {
+			t.Errorf("expected %s before the oldest release to be %v", version, expected)
+		}
+	}
+	if (Releases{}).BeforeOldest("5.5.8") {
+		t.Error("expected no version before the oldest of no release")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	releases, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, release := range releases {
+		if _, err := release.EndOfSupportDate(); err != nil {
+			t.Errorf("release %s: %s", release.Version, err)
+		}
+		if _, _, found := releases.Find(release.Version); !found {
+			t.Errorf("release %s can't be found", release.Version)
+		}
+		if i > 0 {
+			if !mustMinor(t, releases[i-1].Version).LessThan(mustMinor(t, release.Version)) {
+				t.Errorf("release %s is not sorted after %s", release.Version, releases[i-1].Version)
+			}
+		}
+	}
+}
+
+func mustMinor(t *testing.T, version string) *semver.Version {
+	t.Helper()
+	v, err := minor(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}